@@ -0,0 +1,162 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// minimum sample rate able to resolve the 0.5 µs Mode S pulses
+const adsbMinSampleRate = 2000000
+
+// Mode S downlink frequency, and how far from it the recording may be centered
+const adsbFrequency = 1090000000
+const adsbMaxOffset = 500000
+
+// Mode S CRC-24 generator polynomial
+const modesGenerator = 0x1FFF409
+
+type ADSBFrame struct {
+	Sample int    `json:"sample"`
+	Data   []byte `json:"data"`
+}
+
+/**
+ * Detects Mode S preambles in a capture centered on 1090 MHz and demodulates the PPM frames that follow.
+ * Only frames whose parity can be checked without knowing the aircraft address are kept (DF11, DF17, DF18).
+ */
+func extractADSB(samples []complex64, h Header) ([]ADSBFrame, error) {
+	sampleRate := h.SampleRate
+	if sampleRate < adsbMinSampleRate {
+		return nil, fmt.Errorf("sample rate %d is below the %d required for ADS-B", sampleRate, adsbMinSampleRate)
+	}
+	if offset := int64(h.CenterFreq) - adsbFrequency; offset < -adsbMaxOffset || offset > adsbMaxOffset {
+		return nil, fmt.Errorf("center frequency %d is not within %d Hz of %d", h.CenterFreq, adsbMaxOffset, adsbFrequency)
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("no samples")
+	}
+
+	// cumulative magnitude, so that any window average is O(1)
+	sum := make([]float64, len(samples)+1)
+	for i, s := range samples {
+		sum[i+1] = sum[i] + math.Hypot(float64(real(s)), float64(imag(s)))
+	}
+	noise := sum[len(samples)] / float64(len(samples))
+
+	// samples per microsecond
+	spu := float64(sampleRate) / 1e6
+
+	// average magnitude over the half microsecond starting at t µs after i
+	level := func(i int, t float64) float64 {
+		start := i + int(math.Round(t*spu))
+		end := i + int(math.Round((t+0.5)*spu))
+		if end <= start {
+			end = start + 1
+		}
+		return (sum[end] - sum[start]) / float64(end-start)
+	}
+
+	var frames []ADSBFrame
+	frameLen := int(math.Ceil(120 * spu))
+	for i := 0; i+frameLen < len(samples); i++ {
+		// pulses at 0, 1.0, 3.5 and 4.5 µs
+		high := math.Min(math.Min(level(i, 0), level(i, 1.0)), math.Min(level(i, 3.5), level(i, 4.5)))
+		if high < 2*noise {
+			continue
+		}
+
+		// everything else in the first 8 µs must be quiet
+		low := 0.0
+		for _, t := range []float64{0.5, 1.5, 2.0, 2.5, 3.0, 4.0, 5.0, 5.5, 6.0, 6.5, 7.0, 7.5} {
+			low = math.Max(low, level(i, t))
+		}
+		if high < 2*low {
+			continue
+		}
+
+		// pulse position modulation, a pulse in the first half of the bit period is a one
+		bit := func(n int) byte {
+			t := 8 + float64(n)
+			if level(i, t) > level(i, t+0.5) {
+				return 1
+			}
+			return 0
+		}
+
+		var df byte
+		for n := 0; n < 5; n++ {
+			df = df<<1 | bit(n)
+		}
+		bits := 56
+		if df >= 16 {
+			bits = 112
+		}
+
+		data := make([]byte, bits/8)
+		for n := 0; n < bits; n++ {
+			data[n/8] |= bit(n) << (7 - n%8)
+		}
+
+		if !modesParityValid(data) {
+			continue
+		}
+
+		frames = append(frames, ADSBFrame{Sample: i, Data: data})
+
+		// skip over the decoded frame
+		i += int((8 + float64(bits)) * spu)
+	}
+
+	return frames, nil
+}
+
+/**
+ * Checks the 24-bit parity of a DF11, DF17 or DF18 frame
+ */
+func modesParityValid(data []byte) bool {
+	df := data[0] >> 3
+	residual := modesCRC(data[:len(data)-3]) ^ uint32(data[len(data)-3])<<16 ^ uint32(data[len(data)-2])<<8 ^ uint32(data[len(data)-1])
+
+	switch df {
+	case 17, 18:
+		return len(data) == 14 && residual == 0
+	case 11:
+		// all-call replies may carry an interrogator identifier in the low 7 bits
+		return len(data) == 7 && residual&^0x7F == 0
+	}
+
+	return false
+}
+
+/**
+ * Computes the Mode S CRC-24 of a message
+ */
+func modesCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for j := 0; j < 8; j++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= modesGenerator
+			}
+		}
+	}
+
+	return crc & 0xFFFFFF
+}
+
+/**
+ * Formats frames as dump1090 AVR lines ("*<hex>;")
+ */
+func formatAVR(frames []ADSBFrame) []byte {
+	var result []byte
+	for _, frame := range frames {
+		result = append(result, fmt.Sprintf("*%X;\n", frame.Data)...)
+	}
+
+	return result
+}
@@ -0,0 +1,89 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestModesParityValid(t *testing.T) {
+	tests := []struct {
+		frame string
+		valid bool
+	}{
+		{"8D4840D6202CC371C32CE0576098", true},
+		{"8D40621D58C382D690C8AC2863A7", true},
+		{"8D485020994409940838175B284F", true},
+		// one flipped bit in the data, then in the parity
+		{"8D4840D6202CC371C32CE0576099", false},
+		{"8D4841D6202CC371C32CE0576098", false},
+		// DF17 parity on a frame of the wrong length
+		{"8D4840D6202CC3", false},
+		// DF4 parity is overlaid with the address, it cannot be checked
+		{"20001838CA3804", false},
+	}
+
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := modesParityValid(data); got != tt.valid {
+			t.Errorf("%s: got %v, want %v", tt.frame, got, tt.valid)
+		}
+	}
+}
+
+func TestModesParityValidDF11(t *testing.T) {
+	// all-call reply with parity computed over the address, then with an interrogator identifier
+	data := []byte{0x5D, 0x48, 0x40, 0xD6, 0, 0, 0}
+	crc := modesCRC(data[:4])
+	data[4], data[5], data[6] = byte(crc>>16), byte(crc>>8), byte(crc)
+	if !modesParityValid(data) {
+		t.Error("DF11 with interrogator 0 rejected")
+	}
+
+	data[6] ^= 0x05
+	if !modesParityValid(data) {
+		t.Error("DF11 with an interrogator identifier rejected")
+	}
+
+	data[5] ^= 0x01
+	if modesParityValid(data) {
+		t.Error("DF11 with a flipped parity bit accepted")
+	}
+}
+
+func TestExtractADSB(t *testing.T) {
+	frame, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+
+	// 2 MS/s, one sample per half microsecond
+	samples := make([]complex64, 2000)
+	start := 500
+	for _, s := range []int{0, 2, 7, 9} {
+		samples[start+s] = 1
+	}
+	for n := 0; n < 112; n++ {
+		bit := frame[n/8] >> (7 - n%8) & 1
+		samples[start+16+2*n+int(1-bit)] = 1
+	}
+
+	h := Header{SampleRate: 2000000, CenterFreq: 1090000000}
+	frames, err := extractADSB(samples, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || frames[0].Sample != start || hex.EncodeToString(frames[0].Data) != "8d4840d6202cc371c32ce0576098" {
+		t.Fatalf("got %+v", frames)
+	}
+	if got := string(formatAVR(frames)); got != "*8D4840D6202CC371C32CE0576098;\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// recordings not centered on 1090 MHz are rejected
+	h.CenterFreq = 1089000000
+	if _, err := extractADSB(samples, h); err == nil {
+		t.Error("expected an error for a recording centered at 1089 MHz")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type batchJob struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Header  Header
+}
+
+// compares two jobs, negative when a should be converted first
+type batchRule func(a, b *batchJob) int
+
+/**
+ * Converts every .sdriq of a directory in the configured order, a failed file does not stop the batch.
+ * Returns the inputs converted and the files written.
+ */
+func runBatch(dir string, outputDir string, order string) ([]string, []string, error) {
+	jobs, err := listBatch(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules, err := parseBatchOrder(order)
+	if err != nil {
+		return nil, nil, err
+	}
+	orderBatch(jobs, rules)
+
+	err = mkdirOutput(outputDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	var inputs, outputs []string
+
+	failed := 0
+	for _, job := range jobs {
+		name := strings.TrimSuffix(filepath.Base(job.Path), filepath.Ext(job.Path))
+		logrus.WithField("file", job.Path).Info("converting")
+
+		read, written, err := convert(job.Path, filepath.Join(outputDir, name))
+		if err != nil {
+			logrus.WithError(err).WithField("file", job.Path).Error("error converting file")
+			failed++
+			continue
+		}
+		inputs = append(inputs, read...)
+		outputs = append(outputs, written...)
+	}
+
+	if failed > 0 {
+		return inputs, outputs, fmt.Errorf("%d of %d files failed", failed, len(jobs))
+	}
+
+	return inputs, outputs, nil
+}
+
+/**
+ * Lists the .sdriq files of a directory with the metadata the order rules need
+ */
+func listBatch(dir string) ([]*batchJob, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory: %w", err)
+	}
+
+	var jobs []*batchJob
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".sdriq") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		h, err := readHeader(path)
+		if err != nil {
+			logrus.WithError(err).WithField("file", path).Warn("skipping unreadable file")
+			continue
+		}
+
+		jobs = append(jobs, &batchJob{Path: path, Size: entry.Size(), ModTime: entry.ModTime(), Header: h})
+	}
+
+	return jobs, nil
+}
+
+/**
+ * Parses comma separated order rules, earlier rules take precedence
+ */
+func parseBatchOrder(order string) ([]batchRule, error) {
+	var rules []batchRule
+	for _, name := range strings.Split(order, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case name == "newest":
+			rules = append(rules, func(a, b *batchJob) int { return compareTime(b.recorded(), a.recorded()) })
+		case name == "oldest":
+			rules = append(rules, func(a, b *batchJob) int { return compareTime(a.recorded(), b.recorded()) })
+		case name == "smallest":
+			rules = append(rules, func(a, b *batchJob) int { return compareInt(a.Size, b.Size) })
+		case name == "largest":
+			rules = append(rules, func(a, b *batchJob) int { return compareInt(b.Size, a.Size) })
+		case strings.HasPrefix(name, "freq="):
+			freq, err := strconv.ParseFloat(strings.TrimPrefix(name, "freq="), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid frequency in order rule %q", name)
+			}
+			// recordings covering the frequency first
+			rules = append(rules, func(a, b *batchJob) int {
+				return compareBool(b.covers(freq), a.covers(freq))
+			})
+		default:
+			return nil, fmt.Errorf("unknown order rule %q", name)
+		}
+	}
+
+	return rules, nil
+}
+
+/**
+ * Stable sort by the rules, files equal under every rule keep their name order
+ */
+func orderBatch(jobs []*batchJob, rules []batchRule) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		for _, rule := range rules {
+			if c := rule(jobs[i], jobs[j]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+/**
+ * Recording start from the header, falling back to the file time when the header is corrupt
+ */
+func (j *batchJob) recorded() time.Time {
+	if j.Header.CRCValid {
+		return j.Header.Timestamp
+	}
+	return j.ModTime
+}
+
+/**
+ * Whether the recorded band contains freq
+ */
+func (j *batchJob) covers(freq float64) bool {
+	half := float64(j.Header.SampleRate) / 2
+	center := float64(j.Header.CenterFreq)
+	return freq >= center-half && freq <= center+half
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderBatch(t *testing.T) {
+	base := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	job := func(path string, size int64, age time.Duration, center uint64) *batchJob {
+		return &batchJob{
+			Path:   path,
+			Size:   size,
+			Header: Header{SampleRate: 48000, CenterFreq: center, Timestamp: base.Add(-age), CRCValid: true},
+		}
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c", "d"}},
+		{"newest", []string{"c", "a", "d", "b"}},
+		{"oldest", []string{"b", "d", "a", "c"}},
+		{"smallest", []string{"b", "a", "c", "d"}},
+		{"largest", []string{"c", "d", "a", "b"}},
+		{"freq=7010000,newest", []string{"a", "d", "c", "b"}},
+		{"freq=7010000, smallest", []string{"a", "d", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		jobs := []*batchJob{
+			job("a", 200, 2*time.Hour, 7000000),
+			job("b", 100, 4*time.Hour, 14000000),
+			job("c", 300, time.Hour, 14000000),
+			job("d", 300, 3*time.Hour, 7020000),
+		}
+
+		rules, err := parseBatchOrder(tt.order)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.order, err)
+		}
+		orderBatch(jobs, rules)
+
+		for i, j := range jobs {
+			if j.Path != tt.want[i] {
+				var got []string
+				for _, j := range jobs {
+					got = append(got, j.Path)
+				}
+				t.Errorf("%q: got %v, want %v", tt.order, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestOrderBatchCorruptHeader(t *testing.T) {
+	// a corrupt header falls back to the file time
+	old := &batchJob{Path: "old", ModTime: time.Unix(1000, 0), Header: Header{Timestamp: time.Unix(5000, 0)}}
+	recent := &batchJob{Path: "recent", ModTime: time.Unix(3000, 0), Header: Header{Timestamp: time.Unix(2000, 0), CRCValid: true}}
+
+	jobs := []*batchJob{recent, old}
+	rules, err := parseBatchOrder("oldest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderBatch(jobs, rules)
+
+	if jobs[0] != old {
+		t.Errorf("got %s first, want old", jobs[0].Path)
+	}
+}
+
+func TestParseBatchOrderInvalid(t *testing.T) {
+	for _, order := range []string{"biggest", "freq=abc", "newest,random"} {
+		if _, err := parseBatchOrder(order); err == nil {
+			t.Errorf("%q: expected an error", order)
+		}
+	}
+}
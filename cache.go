@@ -0,0 +1,190 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// temporary entries older than this were left by a crashed write
+const staleCacheTemp = time.Hour
+
+// bounded on-disk cache of decoded sample streams, least recently used entries are evicted first
+type decodeCache struct {
+	dir string
+	max int64
+}
+
+/**
+ * Opens the cache, a nil cache decodes without caching
+ */
+func newDecodeCache(dir string, max int64) (*decodeCache, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decodeCache{dir: dir, max: max}, nil
+}
+
+/**
+ * Decodes a sample payload, reusing a previous decode of the same payload when cached.
+ * Entries are keyed by content, so copies of a recording under other names share one entry.
+ */
+func (c *decodeCache) decode(payload []byte, sampleSize uint32) []complex64 {
+	if c == nil {
+		return decodeIQ(payload, sampleSize)
+	}
+
+	key := c.key(payload, sampleSize)
+	if samples, ok := c.load(key, len(payload)/frameSize(sampleSize)); ok {
+		logrus.Info("decoded samples from cache")
+		return samples
+	}
+
+	samples := decodeIQ(payload, sampleSize)
+	err := c.store(key, samples)
+	if err != nil {
+		logrus.WithError(err).Warn("error caching decoded samples")
+	}
+
+	return samples
+}
+
+/**
+ * Identifies a payload by its SHA-256 and sample size
+ */
+func (c *decodeCache) key(payload []byte, sampleSize uint32) string {
+	hash := sha256.New()
+	hash.Write(payload)
+	binary.Write(hash, binary.LittleEndian, sampleSize)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+/**
+ * Reads a cached stream of little-endian float32 I/Q pairs, an entry of another length is a miss
+ */
+func (c *decodeCache) load(key string, frames int) ([]complex64, bool) {
+	path := filepath.Join(c.dir, key+".iq")
+	content, err := ioutil.ReadFile(path)
+	if err != nil || len(content) != frames*8 {
+		return nil, false
+	}
+
+	// mark as recently used
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	samples := make([]complex64, frames)
+	for i := range samples {
+		re := math.Float32frombits(binary.LittleEndian.Uint32(content[i*8:]))
+		im := math.Float32frombits(binary.LittleEndian.Uint32(content[i*8+4:]))
+		samples[i] = complex(re, im)
+	}
+
+	return samples, true
+}
+
+/**
+ * Writes a stream to the cache and evicts old entries to stay within the size limit.
+ * Streams larger than the whole cache are not stored.
+ */
+func (c *decodeCache) store(key string, samples []complex64) error {
+	size := int64(len(samples)) * 8
+	if size > c.max {
+		return nil
+	}
+
+	content := make([]byte, size)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint32(content[i*8:], math.Float32bits(real(s)))
+		binary.LittleEndian.PutUint32(content[i*8+4:], math.Float32bits(imag(s)))
+	}
+
+	err := c.evict(size)
+	if err != nil {
+		return err
+	}
+
+	// rename so a concurrent reader never sees a partial entry
+	tmp, err := ioutil.TempFile(c.dir, key+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, key+".iq"))
+}
+
+/**
+ * Removes least recently used entries until incoming bytes fit within the limit.
+ * Temporary files count towards the limit; stale ones, left by crashed writes, are removed.
+ */
+func (c *decodeCache) evict(incoming int64) error {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	var files []os.FileInfo
+	total := incoming
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch {
+		case strings.Contains(entry.Name(), ".tmp"):
+			if time.Since(entry.ModTime()) > staleCacheTemp {
+				err = os.Remove(filepath.Join(c.dir, entry.Name()))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			// a write in progress
+			total += entry.Size()
+		case strings.HasSuffix(entry.Name(), ".iq"):
+			files = append(files, entry)
+			total += entry.Size()
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, file := range files {
+		if total <= c.max {
+			break
+		}
+		err = os.Remove(filepath.Join(c.dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		total -= file.Size()
+	}
+
+	return nil
+}
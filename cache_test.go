@@ -0,0 +1,105 @@
+//go:build !minimal
+
+package main
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDecodeCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := newDecodeCache(filepath.Join(dir, "cache"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := make([]byte, 8*1000)
+	rand.New(rand.NewSource(1)).Read(payload)
+	want := decodeIQ(payload, 24)
+
+	// the first decode stores, the second one loads
+	for i := 0; i < 2; i++ {
+		got := cache.decode(payload, 24)
+		if len(got) != len(want) {
+			t.Fatalf("run %d: got %d samples, want %d", i, len(got), len(want))
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Fatalf("run %d: sample %d is %v, want %v", i, j, got[j], want[j])
+			}
+		}
+	}
+
+	entries, _ := ioutil.ReadDir(cache.dir)
+	if len(entries) != 1 {
+		t.Fatalf("cache holds %d entries, want 1", len(entries))
+	}
+
+	// an entry is served from the cache, not decoded again
+	key := cache.key(payload, 24)
+	err = cache.store(key, make([]complex64, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.decode(payload, 24); got[0] != 0 {
+		t.Error("payload decoded again instead of loaded")
+	}
+
+	// the same payload read as 16-bit is another stream
+	if got := cache.decode(payload, 16); len(got) != 2000 || got[0] != decodeIQ(payload, 16)[0] {
+		t.Error("16-bit decode served from the 24-bit entry")
+	}
+
+	// a nil cache always decodes
+	var none *decodeCache
+	if got := none.decode(payload, 24); got[0] != want[0] {
+		t.Error("nil cache did not decode")
+	}
+}
+
+func TestDecodeCacheEvict(t *testing.T) {
+	dir := t.TempDir()
+	cache := &decodeCache{dir: dir, max: 250}
+
+	// a temporary file left by a crashed write, and one being written
+	stale := filepath.Join(dir, "a.tmp123")
+	ioutil.WriteFile(stale, make([]byte, 1000), 0644)
+	old := time.Now().Add(-2 * staleCacheTemp)
+	os.Chtimes(stale, old, old)
+	writing := filepath.Join(dir, "b.tmp456")
+	ioutil.WriteFile(writing, make([]byte, 50), 0644)
+
+	for i, key := range []string{"first", "second", "third"} {
+		err := cache.store(key, make([]complex64, 12))
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now().Add(time.Duration(i-10) * time.Second)
+		os.Chtimes(filepath.Join(dir, key+".iq"), at, at)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale temporary file was kept")
+	}
+	if _, err := os.Stat(writing); err != nil {
+		t.Error("temporary file of a write in progress was removed")
+	}
+
+	// 50 bytes in progress leave room for two entries
+	var total int64
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		total += entry.Size()
+	}
+	if total > cache.max {
+		t.Errorf("cache holds %d bytes, limit %d", total, cache.max)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "first.iq")); !os.IsNotExist(err) {
+		t.Error("least recently used entry was kept")
+	}
+}
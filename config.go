@@ -0,0 +1,13 @@
+package main
+
+import "time"
+
+// read access to the parsed flags, through viper in the full build and pflag alone in a minimal one
+type settings interface {
+	GetString(key string) string
+	GetBool(key string) bool
+	GetInt(key string) int
+	GetInt64(key string) int64
+	GetDuration(key string) time.Duration
+	IsSet(key string) bool
+}
@@ -0,0 +1,46 @@
+//go:build minimal
+
+package main
+
+import (
+	flag "github.com/spf13/pflag"
+	"time"
+)
+
+var config settings = flagSettings{flag.CommandLine}
+
+// reads the flags straight from pflag, flags that are not registered read as their zero value
+type flagSettings struct {
+	flags *flag.FlagSet
+}
+
+func bindConfig() {}
+
+func (s flagSettings) GetString(key string) string {
+	value, _ := s.flags.GetString(key)
+	return value
+}
+
+func (s flagSettings) GetBool(key string) bool {
+	value, _ := s.flags.GetBool(key)
+	return value
+}
+
+func (s flagSettings) GetInt(key string) int {
+	value, _ := s.flags.GetInt(key)
+	return value
+}
+
+func (s flagSettings) GetInt64(key string) int64 {
+	value, _ := s.flags.GetInt64(key)
+	return value
+}
+
+func (s flagSettings) GetDuration(key string) time.Duration {
+	value, _ := s.flags.GetDuration(key)
+	return value
+}
+
+func (s flagSettings) IsSet(key string) bool {
+	return s.flags.Changed(key)
+}
@@ -0,0 +1,33 @@
+//go:build minimal
+
+package main
+
+import (
+	flag "github.com/spf13/pflag"
+	"testing"
+	"time"
+)
+
+func TestFlagSettings(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("output", "./raw", "")
+	flags.Bool("follow", false, "")
+	flags.Duration("follow-timeout", 10*time.Second, "")
+	err := flags.Parse([]string{"--follow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := flagSettings{flags}
+	if s.GetString("output") != "./raw" || !s.GetBool("follow") || s.GetDuration("follow-timeout") != 10*time.Second {
+		t.Error("flag values not read")
+	}
+	if !s.IsSet("follow") || s.IsSet("output") {
+		t.Error("only flags given on the command line are set")
+	}
+
+	// feature flags are not registered in a minimal build
+	if s.GetString("serve") != "" || s.GetInt("decimate") != 0 || s.IsSet("adsb") {
+		t.Error("unregistered flags must read as unset")
+	}
+}
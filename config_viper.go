@@ -0,0 +1,32 @@
+//go:build !minimal
+
+package main
+
+import (
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var config settings = viper.GetViper()
+
+/**
+ * Binds the core flags to viper, the feature flags are bound where they are registered
+ */
+func bindConfig() {
+	viper.BindPFlag("input", flag.Lookup("input"))
+	viper.BindPFlag("output", flag.Lookup("output"))
+	viper.BindPFlag("index", flag.Lookup("index"))
+	viper.BindPFlag("index-interval", flag.Lookup("index-interval"))
+	viper.BindPFlag("timestamps", flag.Lookup("timestamps"))
+	viper.BindPFlag("timestamp-interval", flag.Lookup("timestamp-interval"))
+	viper.BindPFlag("payload-crc", flag.Lookup("payload-crc"))
+	viper.BindPFlag("crc-chunk", flag.Lookup("crc-chunk"))
+	viper.BindPFlag("verify", flag.Lookup("verify"))
+	viper.BindPFlag("batch", flag.Lookup("batch"))
+	viper.BindPFlag("order", flag.Lookup("order"))
+	viper.BindPFlag("follow", flag.Lookup("follow"))
+	viper.BindPFlag("follow-timeout", flag.Lookup("follow-timeout"))
+	viper.BindPFlag("mode", flag.Lookup("mode"))
+	viper.BindPFlag("owner", flag.Lookup("owner"))
+	viper.BindPFlag("umask", flag.Lookup("umask"))
+}
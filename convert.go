@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+)
+
+// shape of the converted output
+type outputPlan struct {
+	SampleRate    uint32
+	BitsPerSample int
+	Decimation    int
+}
+
+/**
+ * Default output, 16-bit at the recording's sample rate
+ */
+func defaultPlan(h Header) outputPlan {
+	return outputPlan{SampleRate: h.SampleRate, BitsPerSample: 16, Decimation: 1}
+}
+
+/**
+ * Size of the WAV written for the given number of input frames
+ */
+func (p outputPlan) size(frames int64) int64 {
+	return 44 + frames/int64(p.Decimation)*int64(2*p.BitsPerSample/8)
+}
+
+/**
+ * Bytes per second of the converted samples
+ */
+func (p outputPlan) byteRate() uint32 {
+	return p.SampleRate * uint32(2*p.BitsPerSample/8)
+}
+
+// state of one conversion, shared with the optional features
+type conversion struct {
+	input    string
+	output   string
+	content  []byte
+	header   Header
+	frames   int64
+	plan     outputPlan
+	sidecar  *Sidecar
+	read     []string
+	written  []string
+	features featureState
+}
+
+/**
+ * Converts one .sdriq recording, output is the prefix of every file written.
+ * Returns the paths of the files read, the input first, and of the files written.
+ */
+func convert(input string, output string) ([]string, []string, error) {
+	// read file and save the content in a variable
+	content, err := ioutil.ReadFile(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	// header is the first 32 bytes
+	if len(content) < 32 {
+		return nil, nil, errors.New("input file is too short")
+	}
+
+	// fix header slice into Header struct
+	h := parseHeader(content[:32])
+	if !h.CRCValid {
+		logrus.Info("CRC mismatch")
+	}
+
+	// print header
+	fmt.Println(h.String())
+
+	c := &conversion{
+		input:   input,
+		output:  output,
+		content: content,
+		header:  h,
+		frames:  int64(len(content)-32) / int64(frameSize(h.SampleSize)),
+		plan:    defaultPlan(h),
+		sidecar: &Sidecar{Source: input, Header: h},
+		read:    []string{input},
+	}
+
+	// plan the output, stop here if only an estimate was asked for
+	done, err := featurePlan(c)
+	if err != nil || done {
+		return nil, nil, err
+	}
+
+	// write header to human-readable file
+	err = writeOutput(output+"-info.txt", []byte(h.String()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing file: %w", err)
+	}
+	c.written = append(c.written, output+"-info.txt")
+
+	// write JSON sidecar
+	if config.GetBool("payload-crc") {
+		chunk := config.GetInt("crc-chunk")
+		if chunk <= 0 {
+			chunk = adaptiveCRCChunk(int64(len(content) - 32))
+		}
+		c.sidecar.PayloadChecksums = payloadChecksums(content[32:], chunk)
+	}
+
+	err = writeSidecar(output+"-info.json", c.sidecar)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing file: %w", err)
+	}
+	c.written = append(c.written, output+"-info.json")
+
+	// write seek index
+	if config.GetBool("index") {
+		interval := config.GetDuration("index-interval")
+		entries := buildIndex(h, int64(len(content)), interval)
+		err = writeIndex(output+"-index.idx", h, interval, entries)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error writing index: %w", err)
+		}
+		c.written = append(c.written, output+"-index.idx")
+	}
+
+	// reports on the input samples
+	err = featureAnalysis(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// open output file
+	out, err := createOutput(output + "-iq.wav")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating file: %w", err)
+	}
+	defer out.Close()
+	c.written = append(c.written, out.Name())
+
+	// write wave header to file, sizes are filled in once the samples are written
+	_, err = out.Write(wavHeader(c.plan.SampleRate, 2, c.plan.BitsPerSample))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing file: %w", err)
+	}
+
+	// samples go to the file and, optionally, to a live stream
+	sink, closeSink, err := featureSink(out, c.plan.byteRate(), config.GetBool("udp-pace"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeSink()
+
+	// convert samples to 16 bits, or to the planned format
+	var dataSize int64
+	if c.plan == defaultPlan(h) {
+		dataSize, err = convertTo16BitStream(sink, content[32:], h.SampleSize)
+	} else {
+		dataSize, err = featureSamples(c, sink)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing file: %w", err)
+	}
+
+	// flush the tail of the stream
+	closeSink()
+
+	// write sizes to wave header
+	err = finalizeWav(out, dataSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing file: %w", err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error closing file: %w", err)
+	}
+
+	// outputs derived from the whole recording
+	err = featureOutputs(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// write timestamp track
+	if config.GetString("timestamps") != "" {
+		segments := []TimeSegment{{SampleStart: 0, Samples: dataSize / int64(2*c.plan.BitsPerSample/8), Time: h.Timestamp}}
+
+		var track []byte
+		var path string
+		switch config.GetString("timestamps") {
+		case "csv":
+			track = formatTimestampsCSV(segments, c.plan.SampleRate, config.GetDuration("timestamp-interval"))
+			path = output + "-timestamps.csv"
+		case "sigmf":
+			track, err = formatTimestampsSigMF(segments, h, c.plan, out.Name())
+			path = output + "-iq.sigmf-meta"
+		default:
+			return nil, nil, fmt.Errorf("unknown timestamp format %q", config.GetString("timestamps"))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error formatting timestamps: %w", err)
+		}
+
+		err = writeOutput(path, track)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error writing file: %w", err)
+		}
+		c.written = append(c.written, path)
+	}
+
+	return c.read, c.written, nil
+}
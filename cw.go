@@ -0,0 +1,250 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strings"
+	"time"
+)
+
+// envelope rate after mixing the tone down to baseband
+const cwEnvelopeRate = 500
+
+// silence that separates two transmissions
+const cwTransmissionGap = 2 * time.Second
+
+var morseTable = map[string]string{
+	".-": "A", "-...": "B", "-.-.": "C", "-..": "D", ".": "E", "..-.": "F", "--.": "G", "....": "H",
+	"..": "I", ".---": "J", "-.-": "K", ".-..": "L", "--": "M", "-.": "N", "---": "O", ".--.": "P",
+	"--.-": "Q", ".-.": "R", "...": "S", "-": "T", "..-": "U", "...-": "V", ".--": "W", "-..-": "X",
+	"-.--": "Y", "--..": "Z",
+	"-----": "0", ".----": "1", "..---": "2", "...--": "3", "....-": "4",
+	".....": "5", "-....": "6", "--...": "7", "---..": "8", "----.": "9",
+	".-.-.-": ".", "--..--": ",", "..--..": "?", "-..-.": "/", "-...-": "=", ".-.-.": "+",
+	"-....-": "-", ".--.-.": "@", "-.--.": "(", "-.--.-": ")", "---...": ":",
+}
+
+type CWTransmission struct {
+	Timestamp time.Time `json:"timestamp"`
+	Offset    float64   `json:"offset_hz"`
+	WPM       float64   `json:"wpm"`
+	Text      string    `json:"text"`
+}
+
+type cwRun struct {
+	on     bool
+	start  int
+	length int
+}
+
+/**
+ * Finds the strongest tone of a narrowband recording, keys its envelope and decodes the Morse code.
+ * Each transmission gets its own speed estimate, so different stations in one recording decode correctly.
+ */
+func decodeCW(samples []complex64, sampleRate uint32, start time.Time) []CWTransmission {
+	decimation := int(sampleRate) / cwEnvelopeRate
+	if decimation < 1 || len(samples) < decimation*cwEnvelopeRate {
+		return nil
+	}
+	envelopeRate := float64(sampleRate) / float64(decimation)
+
+	// mix the tone down and integrate each block, which also low-passes it
+	offset := cwToneOffset(samples, sampleRate)
+	envelope := make([]float64, len(samples)/decimation)
+	phaseStep := -2 * math.Pi * offset / float64(sampleRate)
+	for i := range envelope {
+		var acc complex128
+		for j := i * decimation; j < (i+1)*decimation; j++ {
+			acc += complex128(samples[j]) * cmplx.Rect(1, phaseStep*float64(j))
+		}
+		envelope[i] = cmplx.Abs(acc) / float64(decimation)
+	}
+
+	// threshold half way between the noise floor and the keyed level
+	sorted := append([]float64(nil), envelope...)
+	sort.Float64s(sorted)
+	noise := sorted[len(sorted)*20/100]
+	signal := sorted[len(sorted)*95/100]
+	if signal < 4*noise {
+		return nil
+	}
+	threshold := (noise + signal) / 2
+
+	// key down/up runs with a little hysteresis
+	var runs []cwRun
+	on := false
+	for i, v := range envelope {
+		if on && v < threshold*0.9 || !on && v > threshold*1.1 {
+			on = !on
+		}
+		if len(runs) == 0 || runs[len(runs)-1].on != on {
+			runs = append(runs, cwRun{on: on, start: i})
+		}
+		runs[len(runs)-1].length++
+	}
+
+	// split into transmissions on long silences
+	var result []CWTransmission
+	gap := int(cwTransmissionGap.Seconds() * envelopeRate)
+	first := -1
+	for i, run := range runs {
+		if run.on && first < 0 {
+			first = i
+		}
+		if first >= 0 && (!run.on && run.length >= gap || i == len(runs)-1) {
+			if t, ok := decodeCWRuns(runs[first:i+1], envelopeRate); ok {
+				t.Timestamp = start.Add(time.Duration(float64(runs[first].start) / envelopeRate * float64(time.Second)))
+				t.Offset = offset
+				result = append(result, t)
+			}
+			first = -1
+		}
+	}
+
+	return result
+}
+
+/**
+ * Locates the strongest tone with an averaged power spectrum, returns its offset from the center in Hz
+ */
+func cwToneOffset(samples []complex64, sampleRate uint32) float64 {
+	// about 10 Hz resolution
+	size := nextPow2(int(sampleRate) / 10)
+	if size > len(samples) {
+		size = nextPow2(len(samples)/2 + 1)
+	}
+
+	power := make([]float64, size)
+	buf := make([]complex128, size)
+	for segment := 0; segment+size <= len(samples); segment += size {
+		for i := range buf {
+			// hann window
+			w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+			buf[i] = complex128(samples[segment+i]) * complex(w, 0)
+		}
+		fft(buf)
+		for i, v := range buf {
+			power[i] += real(v)*real(v) + imag(v)*imag(v)
+		}
+	}
+
+	peak := 0
+	for i := range power {
+		if power[i] > power[peak] {
+			peak = i
+		}
+	}
+	if peak >= size/2 {
+		peak -= size
+	}
+
+	return float64(peak) * float64(sampleRate) / float64(size)
+}
+
+/**
+ * Decodes one transmission, estimating the dot length from its key-down durations
+ */
+func decodeCWRuns(runs []cwRun, envelopeRate float64) (CWTransmission, bool) {
+	var durations []float64
+	for _, run := range runs {
+		if run.on {
+			durations = append(durations, float64(run.length))
+		}
+	}
+	if len(durations) < 2 {
+		return CWTransmission{}, false
+	}
+
+	// two-means clustering of dots and dashes
+	sort.Float64s(durations)
+	dot, dash := durations[0], durations[len(durations)-1]
+	for iteration := 0; iteration < 10; iteration++ {
+		var dots, dashes []float64
+		for _, d := range durations {
+			if d-dot < dash-d {
+				dots = append(dots, d)
+			} else {
+				dashes = append(dashes, d)
+			}
+		}
+		if len(dots) > 0 {
+			dot = mean(dots)
+		}
+		if len(dashes) > 0 {
+			dash = mean(dashes)
+		}
+	}
+
+	// a transmission of only dots or only dashes
+	if dash < 2*dot {
+		if dot*1000/envelopeRate > 90 {
+			dash, dot = dot, dot/3
+		} else {
+			dash = 3 * dot
+		}
+	}
+	unit := (dot + dash/3) / 2
+
+	var text strings.Builder
+	var symbol strings.Builder
+	flush := func() {
+		if symbol.Len() == 0 {
+			return
+		}
+		if letter, ok := morseTable[symbol.String()]; ok {
+			text.WriteString(letter)
+		} else {
+			text.WriteString("*")
+		}
+		symbol.Reset()
+	}
+
+	for _, run := range runs {
+		length := float64(run.length)
+		switch {
+		case run.on && length < 2*unit:
+			symbol.WriteByte('.')
+		case run.on:
+			symbol.WriteByte('-')
+		case length >= 5*unit:
+			flush()
+			text.WriteString(" ")
+		case length >= 2*unit:
+			flush()
+		}
+	}
+	flush()
+
+	// PARIS standard, one dot is 1.2 / WPM seconds
+	wpm := 1.2 / (unit / envelopeRate)
+
+	return CWTransmission{WPM: math.Round(wpm*10) / 10, Text: strings.TrimSpace(text.String())}, true
+}
+
+/**
+ * Formats decoded transmissions as a report, one line per transmission
+ */
+func formatCW(transmissions []CWTransmission) []byte {
+	var result strings.Builder
+	for _, t := range transmissions {
+		fmt.Fprintf(&result, "%s\t%+.1f Hz\t%.1f WPM\t%s\n", t.Timestamp.Format(time.RFC3339Nano), t.Offset, t.WPM, t.Text)
+	}
+
+	return []byte(result.String())
+}
+
+/**
+ * Arithmetic mean of a non-empty slice
+ */
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum / float64(len(values))
+}
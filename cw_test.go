@@ -0,0 +1,117 @@
+//go:build !minimal
+
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+ * Keys a tone with Morse code at wpm, appended to samples
+ */
+func keyCW(samples []complex64, text string, wpm float64, offset float64, sampleRate uint32) []complex64 {
+	codes := make(map[rune]string)
+	for code, letter := range morseTable {
+		codes[rune(letter[0])] = code
+	}
+
+	unit := int(1.2 / wpm * float64(sampleRate))
+	key := func(on bool, units int) {
+		for i := 0; i < units*unit; i++ {
+			var v complex64
+			if on {
+				phase := 2 * math.Pi * offset * float64(len(samples)) / float64(sampleRate)
+				v = complex64(cmplx.Rect(0.5, phase))
+			}
+			samples = append(samples, v)
+		}
+	}
+
+	for i, word := range strings.Fields(text) {
+		if i > 0 {
+			key(false, 7)
+		}
+		for j, letter := range word {
+			if j > 0 {
+				key(false, 3)
+			}
+			for k, symbol := range codes[letter] {
+				if k > 0 {
+					key(false, 1)
+				}
+				if symbol == '.' {
+					key(true, 1)
+				} else {
+					key(true, 3)
+				}
+			}
+		}
+	}
+
+	return samples
+}
+
+func TestDecodeCW(t *testing.T) {
+	const rate = 8000
+	silence := func(samples []complex64, d time.Duration) []complex64 {
+		return append(samples, make([]complex64, int(d.Seconds()*rate))...)
+	}
+
+	// two stations on the same tone at different speeds, separated by a long silence
+	samples := silence(nil, time.Second)
+	samples = keyCW(samples, "PARIS PARIS", 20, 700, rate)
+	samples = silence(samples, 3*time.Second)
+	samples = keyCW(samples, "CQ DE K1ABC", 12, 700, rate)
+	samples = silence(samples, time.Second)
+
+	random := rand.New(rand.NewSource(1))
+	for i := range samples {
+		samples[i] += complex(float32(random.NormFloat64()*0.02), float32(random.NormFloat64()*0.02))
+	}
+
+	start := time.Unix(1600000000, 0)
+	transmissions := decodeCW(samples, rate, start)
+	if len(transmissions) != 2 {
+		t.Fatalf("got %d transmissions: %+v", len(transmissions), transmissions)
+	}
+
+	want := []struct {
+		text string
+		wpm  float64
+	}{
+		{"PARIS PARIS", 20},
+		{"CQ DE K1ABC", 12},
+	}
+	for i, w := range want {
+		got := transmissions[i]
+		if got.Text != w.text {
+			t.Errorf("transmission %d: got %q, want %q", i, got.Text, w.text)
+		}
+		if math.Abs(got.WPM-w.wpm) > 1 {
+			t.Errorf("transmission %d: got %.1f WPM, want %.0f", i, got.WPM, w.wpm)
+		}
+		if math.Abs(got.Offset-700) > 10 {
+			t.Errorf("transmission %d: tone at %.1f Hz, want 700", i, got.Offset)
+		}
+	}
+	if d := transmissions[0].Timestamp.Sub(start) - time.Second; d < -10*time.Millisecond || d > 10*time.Millisecond {
+		t.Errorf("first transmission at %s", transmissions[0].Timestamp.Sub(start))
+	}
+}
+
+func TestDecodeCWNoTone(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	samples := make([]complex64, 8000*5)
+	for i := range samples {
+		samples[i] = complex(float32(random.NormFloat64()*0.1), float32(random.NormFloat64()*0.1))
+	}
+
+	if transmissions := decodeCW(samples, 8000, time.Now()); len(transmissions) != 0 {
+		t.Errorf("decoded noise as %+v", transmissions)
+	}
+}
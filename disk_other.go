@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !minimal
+
+package main
+
+import "errors"
+
+/**
+ * Free space is not available on this platform
+ */
+func freeSpace(path string) (int64, error) {
+	return 0, errors.New("free space check not supported on this platform")
+}
@@ -0,0 +1,18 @@
+//go:build (linux || darwin || freebsd) && !minimal
+
+package main
+
+import "syscall"
+
+/**
+ * Bytes available to unprivileged users on the filesystem holding path
+ */
+func freeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
+}
@@ -0,0 +1,78 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// largest decimation the fallback will apply
+const maxFallbackDecimation = 64
+
+/**
+ * Applies the fallback steps in order (8bit, decimate) to the requested plan until the output fits
+ * in the free space of dir with minFree to spare. Returns no Fallback when the requested output already fits.
+ * diversity is the number of aligned frames of a --merge output written with the same plan, 0 without one.
+ */
+func planFallback(h Header, plan outputPlan, frames int64, diversity int64, dir string, minFree int64, steps string) (outputPlan, *Fallback, error) {
+	requested := plan
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		logrus.WithError(err).Warn("cannot check free space, keeping the default output")
+		return plan, nil, nil
+	}
+
+	required := func(p outputPlan) int64 {
+		size := p.size(frames)
+		if diversity > 0 {
+			size += p.diversitySize(diversity)
+		}
+		return size
+	}
+	fits := func(p outputPlan) bool {
+		return free-required(p) >= minFree
+	}
+	if fits(plan) {
+		return plan, nil, nil
+	}
+
+	record := func(p outputPlan) *Fallback {
+		return &Fallback{
+			Reason:        "low disk space",
+			FreeBytes:     free,
+			RequiredBytes: required(requested),
+			BitsPerSample: p.BitsPerSample,
+			Decimation:    p.Decimation,
+			SampleRate:    p.SampleRate,
+		}
+	}
+
+	for _, step := range strings.Split(steps, ",") {
+		switch strings.TrimSpace(step) {
+		case "8bit":
+			plan.BitsPerSample = 8
+			if fits(plan) {
+				return plan, record(plan), nil
+			}
+		case "decimate":
+			for factor := requested.Decimation * 2; factor <= maxFallbackDecimation; factor *= 2 {
+				// only factors giving an exact WAV sample rate
+				if h.SampleRate%uint32(factor) != 0 {
+					break
+				}
+				plan.Decimation = factor
+				plan.SampleRate = h.SampleRate / uint32(factor)
+				if fits(plan) {
+					return plan, record(plan), nil
+				}
+			}
+		default:
+			return plan, nil, fmt.Errorf("unknown fallback step %q", step)
+		}
+	}
+
+	return plan, nil, fmt.Errorf("output needs %d bytes, %d free with %d to keep free (--min-free)", required(requested), free, minFree)
+}
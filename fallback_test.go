@@ -0,0 +1,62 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPlanFallback(t *testing.T) {
+	dir := os.TempDir()
+	free, err := freeSpace(dir)
+	if err != nil || free < 1<<20 {
+		t.Skip("free space unknown")
+	}
+
+	// room for a quarter of the default output, with a margin for other writers on the filesystem
+	h := Header{SampleRate: 48000, SampleSize: 24}
+	plan := defaultPlan(h)
+	frames := free / 64
+	minFree := free - plan.size(frames)/4 - plan.size(frames)/16
+
+	tests := []struct {
+		steps      string
+		bits       int
+		decimation int
+	}{
+		{"8bit,decimate", 8, 2},
+		{"decimate", 16, 4},
+		{"decimate,8bit", 16, 4},
+	}
+
+	for _, tt := range tests {
+		planned, fallback, err := planFallback(h, plan, frames, 0, dir, minFree, tt.steps)
+		if err != nil {
+			t.Errorf("%s: %v", tt.steps, err)
+			continue
+		}
+		if planned.BitsPerSample != tt.bits || planned.Decimation != tt.decimation || planned.SampleRate != 48000/uint32(tt.decimation) {
+			t.Errorf("%s: got %+v", tt.steps, planned)
+		}
+		if fallback == nil || fallback.RequiredBytes != plan.size(frames) || fallback.Decimation != tt.decimation {
+			t.Errorf("%s: recorded %+v", tt.steps, fallback)
+		}
+	}
+
+	// 8-bit alone only halves the output
+	_, _, err = planFallback(h, plan, frames, 0, dir, minFree, "8bit")
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("%d to keep free", minFree)) {
+		t.Errorf("8bit: got %v, want an error naming --min-free", err)
+	}
+	if _, _, err := planFallback(h, plan, frames, 0, dir, minFree, "compress"); err == nil {
+		t.Error("expected an error for an unknown step")
+	}
+
+	// no fallback when the output fits
+	if planned, fallback, err := planFallback(h, plan, frames, 0, dir, 0, "8bit"); err != nil || fallback != nil || planned != plan {
+		t.Errorf("fits: got %+v, %+v, %v", planned, fallback, err)
+	}
+}
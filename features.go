@@ -0,0 +1,452 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// per-conversion state of the full build
+type featureState struct {
+	cache   *decodeCache
+	decoded []complex64
+	second  []complex64
+}
+
+/**
+ * Registers the flags of the network, DSP and analysis features, which a minimal build leaves out
+ */
+func registerFeatureFlags() {
+	var adsb bool
+	var cw bool
+	var fftBackend string
+	var compare string
+	var udp string
+	var udpPace bool
+	var fallback string
+	var minFree int64
+	var decimation int
+	var quality string
+	var estimate bool
+	var serveAddress string
+	var recordings string
+	var manifest string
+	var manifestKey string
+	var verifyManifestPath string
+	var trustedKey string
+	var cacheDir string
+	var cacheMax int64
+	var merge string
+
+	flag.BoolVar(&adsb, "adsb", false, "extract ADS-B (Mode S) frames to an AVR file")
+	flag.BoolVar(&cw, "cw", false, "decode Morse (CW) to a text report")
+	flag.StringVar(&fftBackend, "fft", "go", "FFT backend used by the analysis (go, fftw)")
+	flag.StringVar(&compare, "compare", "", "compare the input against this converted .wav or round-tripped .sdriq instead of converting")
+	flag.StringVar(&udp, "udp", "", "also stream the converted samples to this UDP host:port")
+	flag.BoolVar(&udpPace, "udp-pace", true, "pace the UDP stream at the recording's sample rate")
+	flag.StringVar(&fallback, "fallback", "", "on low disk space, apply these steps in order until the output fits (8bit, decimate)")
+	flag.Int64Var(&minFree, "min-free", 0, "bytes to leave free on the output filesystem when using --fallback")
+	flag.IntVar(&decimation, "decimate", 1, "decimate the output by this factor")
+	flag.StringVar(&quality, "quality", "medium", "decimation filter quality (fast, medium, best)")
+	flag.BoolVar(&estimate, "estimate", false, "print the output size and measured filter figures without converting")
+	flag.StringVar(&serveAddress, "serve", "", "serve the web UI on this address, output is then a directory")
+	flag.StringVar(&recordings, "recordings", ".", "directory of recordings listed by the web UI")
+	flag.StringVar(&manifest, "manifest", "", "write a signed manifest of the run's inputs, outputs and parameters")
+	flag.StringVar(&manifestKey, "manifest-key", "", "Ed25519 signing key (PKCS#8 PEM) for --manifest")
+	flag.StringVar(&verifyManifestPath, "verify-manifest", "", "check a signed manifest and the files it lists, then exit")
+	flag.StringVar(&trustedKey, "trusted-key", "", "hex Ed25519 public key the manifest must be signed with, required for a verified result")
+	flag.StringVar(&cacheDir, "cache-dir", "", "cache decoded samples in this directory for repeated conversions")
+	flag.Int64Var(&cacheMax, "cache-max", 4<<30, "maximum size of the decode cache in bytes")
+	flag.StringVar(&merge, "merge", "", "second recording of the band to align and merge into a 4-channel diversity WAV")
+
+	//bind flags to viper
+	viper.BindPFlag("adsb", flag.Lookup("adsb"))
+	viper.BindPFlag("cw", flag.Lookup("cw"))
+	viper.BindPFlag("fft", flag.Lookup("fft"))
+	viper.BindPFlag("compare", flag.Lookup("compare"))
+	viper.BindPFlag("udp", flag.Lookup("udp"))
+	viper.BindPFlag("udp-pace", flag.Lookup("udp-pace"))
+	viper.BindPFlag("fallback", flag.Lookup("fallback"))
+	viper.BindPFlag("min-free", flag.Lookup("min-free"))
+	viper.BindPFlag("decimate", flag.Lookup("decimate"))
+	viper.BindPFlag("quality", flag.Lookup("quality"))
+	viper.BindPFlag("estimate", flag.Lookup("estimate"))
+	viper.BindPFlag("serve", flag.Lookup("serve"))
+	viper.BindPFlag("recordings", flag.Lookup("recordings"))
+	viper.BindPFlag("manifest", flag.Lookup("manifest"))
+	viper.BindPFlag("manifest-key", flag.Lookup("manifest-key"))
+	viper.BindPFlag("verify-manifest", flag.Lookup("verify-manifest"))
+	viper.BindPFlag("trusted-key", flag.Lookup("trusted-key"))
+	viper.BindPFlag("cache-dir", flag.Lookup("cache-dir"))
+	viper.BindPFlag("cache-max", flag.Lookup("cache-max"))
+	viper.BindPFlag("merge", flag.Lookup("merge"))
+}
+
+/**
+ * Sets up the features once the flags are parsed, then verifies a signed manifest instead of converting
+ */
+func startFeatures() {
+	// select fft backend
+	if err := selectFFT(viper.GetString("fft")); err != nil {
+		logrus.WithError(err).Fatal("error selecting FFT backend")
+	}
+
+	// fail before converting rather than after
+	if viper.GetString("manifest") != "" && viper.GetString("manifest-key") == "" {
+		logrus.Fatal("--manifest-key is required with --manifest")
+	}
+	if viper.GetString("manifest") != "" && viper.GetString("serve") != "" {
+		logrus.Fatal("--manifest is not supported with --serve, the server runs until it is stopped")
+	}
+
+	// verify a signed manifest
+	if viper.GetString("verify-manifest") != "" {
+		var trusted ed25519.PublicKey
+		if viper.GetString("trusted-key") != "" {
+			key, err := hex.DecodeString(viper.GetString("trusted-key"))
+			if err != nil || len(key) != ed25519.PublicKeySize {
+				logrus.Fatal("trusted key must be a hex Ed25519 public key")
+			}
+			trusted = key
+		}
+
+		err := verifyManifest(viper.GetString("verify-manifest"), trusted)
+		if errors.Is(err, errUnauthenticated) {
+			logrus.Fatal(err)
+		}
+		if err != nil {
+			logrus.WithError(err).Fatal("error verifying manifest")
+		}
+		logrus.Info("manifest verified")
+		os.Exit(0)
+	}
+}
+
+/**
+ * Serves the web UI until it stops, returns false when --serve is not set
+ */
+func featureServe() bool {
+	if viper.GetString("serve") == "" {
+		return false
+	}
+
+	err := serve(viper.GetString("serve"), viper.GetString("recordings"), viper.GetString("output"))
+	if err != nil {
+		logrus.WithError(err).Fatal("error serving")
+	}
+	return true
+}
+
+/**
+ * Compares a converted copy against the input and writes the fidelity report instead of converting.
+ * Returns the files read and the report written.
+ */
+func featureCompare(input string, compare string, output string) ([]string, []string, error) {
+	content, err := ioutil.ReadFile(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %w", err)
+	}
+	if len(content) < 32 {
+		return nil, nil, errors.New("input file is too short")
+	}
+	h := parseHeader(content[:32])
+
+	converted, err := ioutil.ReadFile(compare)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	var copied []complex64
+	var rate uint32
+	if len(converted) >= 4 && string(converted[0:4]) == "RIFF" {
+		copied, rate, err = decodeWav(converted)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error decoding WAV: %w", err)
+		}
+	} else {
+		if len(converted) < 32 {
+			return nil, nil, errors.New("compare file is too short")
+		}
+		ch := parseHeader(converted[:32])
+		copied, rate = decodeIQ(converted[32:], ch.SampleSize), ch.SampleRate
+	}
+
+	// a decimated or resampled copy has no sample by sample counterpart
+	if rate != h.SampleRate {
+		return nil, nil, fmt.Errorf("sample rate differs: %d Hz, input is %d Hz", rate, h.SampleRate)
+	}
+
+	cache, err := newDecodeCache(viper.GetString("cache-dir"), viper.GetInt64("cache-max"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening cache: %w", err)
+	}
+
+	report, err := compareFidelity(cache.decode(content[32:], h.SampleSize), copied, h.SampleSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error comparing samples: %w", err)
+	}
+	fmt.Println(report.String())
+
+	err = writeOutput(output+"-fidelity.txt", []byte(report.String()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing file: %w", err)
+	}
+
+	return []string{input, compare}, []string{output + "-fidelity.txt"}, nil
+}
+
+/**
+ * Writes the signed manifest of the run, if requested
+ */
+func finishFeatures(started time.Time, inputs []string, outputs []string) {
+	if viper.GetString("manifest") == "" {
+		return
+	}
+
+	err := writeManifest(viper.GetString("manifest"), viper.GetString("manifest-key"), started, viper.AllSettings(), inputs, outputs)
+	if err != nil {
+		logrus.WithError(err).Fatal("error writing manifest")
+	}
+}
+
+/**
+ * Decoded samples of the input, through the decode cache when configured, decoded at most once
+ */
+func (c *conversion) samples() []complex64 {
+	if c.features.decoded == nil {
+		c.features.decoded = c.features.cache.decode(c.content[32:], c.header.SampleSize)
+	}
+
+	return c.features.decoded
+}
+
+/**
+ * Applies decimation, the disk space fallback and the diversity alignment to the plan.
+ * Returns true when only an estimate was asked for, which is printed instead of converting.
+ */
+func featurePlan(c *conversion) (bool, error) {
+	h := c.header
+
+	// an unknown quality is an error even when nothing is decimated
+	_, err := decimationFilter(1, viper.GetString("quality"))
+	if err != nil {
+		return false, err
+	}
+
+	cache, err := newDecodeCache(viper.GetString("cache-dir"), viper.GetInt64("cache-max"))
+	if err != nil {
+		return false, fmt.Errorf("error opening cache: %w", err)
+	}
+	c.features.cache = cache
+
+	if factor := viper.GetInt("decimate"); factor > 1 {
+		if h.SampleRate%uint32(factor) != 0 {
+			return false, fmt.Errorf("sample rate %d is not divisible by %d", h.SampleRate, factor)
+		}
+		c.plan.Decimation = factor
+		c.plan.SampleRate = h.SampleRate / uint32(factor)
+	}
+
+	// align a second recording of the band for diversity output, before the fallback so it is counted
+	var diversity int64
+	if viper.GetString("merge") != "" {
+		other, err := ioutil.ReadFile(viper.GetString("merge"))
+		if err != nil {
+			return false, fmt.Errorf("error reading file: %w", err)
+		}
+		if len(other) < 32 {
+			return false, errors.New("merge file is too short")
+		}
+		oh := parseHeader(other[:32])
+		c.features.second = c.features.cache.decode(other[32:], oh.SampleSize)
+
+		alignment, err := alignDiversity(h, c.samples(), oh, c.features.second)
+		if err != nil {
+			return false, fmt.Errorf("error aligning recordings: %w", err)
+		}
+		alignment.Second = viper.GetString("merge")
+		c.read = append(c.read, viper.GetString("merge"))
+		logrus.WithFields(logrus.Fields{
+			"offset":      alignment.SampleOffset,
+			"correlation": alignment.Correlation,
+			"shift":       alignment.FrequencyShift,
+		}).Info("recordings aligned")
+		c.sidecar.Diversity = alignment
+		diversity = int64(alignment.Samples)
+	}
+
+	// fall back to a more compact output when the disk is nearly full
+	if viper.GetString("fallback") != "" {
+		c.plan, c.sidecar.Fallback, err = planFallback(h, c.plan, c.frames, diversity, filepath.Dir(c.output), viper.GetInt64("min-free"), viper.GetString("fallback"))
+		if err != nil {
+			return false, fmt.Errorf("error planning output: %w", err)
+		}
+		if c.sidecar.Fallback != nil {
+			logrus.WithFields(logrus.Fields{
+				"bits":       c.plan.BitsPerSample,
+				"decimation": c.plan.Decimation,
+			}).Warn("low disk space, writing a more compact output")
+		}
+	}
+
+	// print the estimate and stop
+	if viper.GetBool("estimate") {
+		fmt.Printf("Output: %d bytes, %d Hz, %d bits, decimation %d\n", c.plan.size(c.frames), c.plan.SampleRate, c.plan.BitsPerSample, c.plan.Decimation)
+		if diversity > 0 {
+			fmt.Printf("Diversity output: %d bytes\n", c.plan.diversitySize(diversity))
+		}
+
+		// measure on up to a second of samples
+		measured := c.samples()
+		if len(measured) > int(h.SampleRate) {
+			measured = measured[:h.SampleRate]
+		}
+		factor := c.plan.Decimation
+		if factor < 2 {
+			factor = 2
+		}
+
+		fmt.Printf("Decimation filters (factor %d):\n", factor)
+		for _, e := range estimateFilters(factor, measured) {
+			fmt.Printf("  %-6s %5d taps  %6.1f dB stop-band  %.4f dB ripple  %6.2f MS/s\n", e.Quality, e.Taps, e.StopBand, e.Ripple, e.Throughput/1e6)
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+/**
+ * Writes the ADS-B and CW reports
+ */
+func featureAnalysis(c *conversion) error {
+	h := c.header
+
+	// extract ADS-B frames
+	if viper.GetBool("adsb") {
+		frames, err := extractADSB(c.samples(), h)
+		if err != nil {
+			return fmt.Errorf("error extracting ADS-B frames: %w", err)
+		}
+
+		err = writeOutput(c.output+"-adsb.avr", formatAVR(frames))
+		if err != nil {
+			return fmt.Errorf("error writing file: %w", err)
+		}
+		c.written = append(c.written, c.output+"-adsb.avr")
+		logrus.Info("ADS-B frames: ", len(frames))
+	}
+
+	// decode Morse
+	if viper.GetBool("cw") {
+		transmissions := decodeCW(c.samples(), h.SampleRate, h.Timestamp)
+
+		err := writeOutput(c.output+"-cw.txt", formatCW(transmissions))
+		if err != nil {
+			return fmt.Errorf("error writing file: %w", err)
+		}
+		c.written = append(c.written, c.output+"-cw.txt")
+		logrus.Info("CW transmissions: ", len(transmissions))
+	}
+
+	return nil
+}
+
+/**
+ * Adds the live UDP stream to the samples written to out, when configured.
+ * The returned function flushes and closes the stream, calling it again does nothing.
+ */
+func featureSink(out io.Writer, byteRate uint32, pace bool) (io.Writer, func(), error) {
+	if viper.GetString("udp") == "" {
+		return out, func() {}, nil
+	}
+
+	stream, err := newUDPStream(viper.GetString("udp"), byteRate, pace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening UDP stream: %w", err)
+	}
+
+	closed := false
+	closeStream := func() {
+		if closed {
+			return
+		}
+		closed = true
+		err := stream.Close()
+		if err != nil {
+			logrus.WithError(err).Warn("error closing UDP stream")
+		}
+	}
+
+	return io.MultiWriter(out, stream), closeStream, nil
+}
+
+/**
+ * Writes the samples decimated and requantized as planned
+ */
+func featureSamples(c *conversion, w io.Writer) (int64, error) {
+	coefficients, err := decimationFilter(c.plan.Decimation, viper.GetString("quality"))
+	if err != nil {
+		return 0, err
+	}
+
+	return writeSamples(w, decimate(c.samples(), c.plan.Decimation, coefficients), c.plan.BitsPerSample)
+}
+
+/**
+ * Writes the 4-channel diversity file with the same decimation and bit depth as the main output
+ */
+func featureOutputs(c *conversion) error {
+	alignment := c.sidecar.Diversity
+	if alignment == nil {
+		return nil
+	}
+
+	first, second := mergeDiversity(c.samples(), c.features.second, alignment, c.header.SampleRate)
+	if c.plan.Decimation > 1 {
+		coefficients, err := decimationFilter(c.plan.Decimation, viper.GetString("quality"))
+		if err != nil {
+			return err
+		}
+		first = decimate(first, c.plan.Decimation, coefficients)
+		second = decimate(second, c.plan.Decimation, coefficients)
+	}
+
+	merged, err := createOutput(c.output + "-diversity.wav")
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer merged.Close()
+	c.written = append(c.written, merged.Name())
+
+	_, err = merged.Write(wavHeader(c.plan.SampleRate, 4, c.plan.BitsPerSample))
+	if err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	size, err := writeSamples(merged, interleave(first, second), c.plan.BitsPerSample)
+	if err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	err = finalizeWav(merged, size)
+	if err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	err = merged.Close()
+	if err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	return nil
+}
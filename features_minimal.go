@@ -0,0 +1,55 @@
+//go:build minimal
+
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// a minimal build keeps no per-conversion state
+type featureState struct{}
+
+/**
+ * A minimal build has only the core conversion flags
+ */
+func registerFeatureFlags() {}
+
+func startFeatures() {}
+
+func featureServe() bool {
+	return false
+}
+
+func featureCompare(input string, compare string, output string) ([]string, []string, error) {
+	return nil, nil, errors.New("the fidelity report is not available in a minimal build")
+}
+
+func finishFeatures(started time.Time, inputs []string, outputs []string) {}
+
+/**
+ * The plan stays the default 16-bit output
+ */
+func featurePlan(c *conversion) (bool, error) {
+	return false, nil
+}
+
+func featureAnalysis(c *conversion) error {
+	return nil
+}
+
+/**
+ * Samples only go to the output file
+ */
+func featureSink(out io.Writer, byteRate uint32, pace bool) (io.Writer, func(), error) {
+	return out, func() {}, nil
+}
+
+func featureSamples(c *conversion, w io.Writer) (int64, error) {
+	return 0, errors.New("decimation and requantization are not available in a minimal build")
+}
+
+func featureOutputs(c *conversion) error {
+	return nil
+}
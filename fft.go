@@ -0,0 +1,69 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// forward FFT used by the analysis code, replaced at startup by the --fft selection
+var fft = fftRadix2
+
+// available FFT backends, optional ones register themselves from build-tagged files
+var fftBackends = map[string]func([]complex128){
+	"go": fftRadix2,
+}
+
+/**
+ * Selects the FFT backend by name
+ */
+func selectFFT(name string) error {
+	backend, ok := fftBackends[name]
+	if !ok {
+		var names []string
+		for n := range fftBackends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown FFT backend %q, available: %s", name, strings.Join(names, ", "))
+	}
+	fft = backend
+
+	return nil
+}
+
+/**
+ * In-place iterative radix-2 FFT, len(x) must be a power of two
+ */
+func fftRadix2(x []complex128) {
+	n := len(x)
+	if n < 2 {
+		return
+	}
+	shift := 64 - bits.TrailingZeros(uint(n))
+
+	// bit reversal permutation
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if j > i {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := -2 * math.Pi / float64(size)
+		for start := 0; start < n; start += size {
+			for k := 0; k < half; k++ {
+				sin, cos := math.Sincos(step * float64(k))
+				t := complex(cos, sin) * x[start+k+half]
+				x[start+k+half] = x[start+k] - t
+				x[start+k] += t
+			}
+		}
+	}
+}
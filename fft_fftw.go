@@ -0,0 +1,57 @@
+//go:build fftw && cgo && !minimal
+
+package main
+
+/*
+#cgo LDFLAGS: -lfftw3 -lm
+#include <fftw3.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// FFTW plan with its own aligned buffer, one per transform size
+type fftwPlan struct {
+	plan C.fftw_plan
+	buf  *C.fftw_complex
+}
+
+var fftwMutex sync.Mutex
+var fftwPlans = map[int]*fftwPlan{}
+
+func init() {
+	fftBackends["fftw"] = fftw
+}
+
+/**
+ * In-place forward FFT through FFTW, plans are created once per size and reused
+ */
+func fftw(x []complex128) {
+	n := len(x)
+	if n < 2 {
+		return
+	}
+
+	// planning and the shared buffers are not safe for concurrent use
+	fftwMutex.Lock()
+	defer fftwMutex.Unlock()
+
+	p, ok := fftwPlans[n]
+	if !ok {
+		buf := (*C.fftw_complex)(C.fftw_malloc(C.size_t(n) * C.sizeof_fftw_complex))
+		p = &fftwPlan{
+			plan: C.fftw_plan_dft_1d(C.int(n), buf, buf, C.FFTW_FORWARD, C.FFTW_ESTIMATE),
+			buf:  buf,
+		}
+		fftwPlans[n] = p
+	}
+
+	// fftw_complex is laid out like complex128
+	data := unsafe.Slice((*complex128)(unsafe.Pointer(p.buf)), n)
+	copy(data, x)
+	C.fftw_execute(p.plan)
+	copy(x, data)
+}
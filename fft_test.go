@@ -0,0 +1,50 @@
+//go:build !minimal
+
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestFFTBackends(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for name, backend := range fftBackends {
+		for _, n := range []int{1, 2, 8, 64, 1024} {
+			x := make([]complex128, n)
+			for i := range x {
+				x[i] = complex(random.NormFloat64(), random.NormFloat64())
+			}
+
+			// naive DFT
+			want := make([]complex128, n)
+			for k := range want {
+				for i, v := range x {
+					want[k] += v * cmplx.Rect(1, -2*math.Pi*float64(k*i)/float64(n))
+				}
+			}
+
+			backend(x)
+			for k := range x {
+				if cmplx.Abs(x[k]-want[k]) > 1e-9*float64(n) {
+					t.Errorf("%s, n=%d: bin %d is %v, want %v", name, n, k, x[k], want[k])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestSelectFFT(t *testing.T) {
+	defer func() { fft = fftRadix2 }()
+
+	if err := selectFFT("kissfft"); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+	if err := selectFFT("go"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+type FidelityReport struct {
+	Samples          int     `json:"samples"`
+	ReferenceSamples int     `json:"reference_samples"`
+	MaxError         float64 `json:"max_error"`
+	MaxErrorLSB      float64 `json:"max_error_lsb"`
+	RMSError         float64 `json:"rms_error"`
+	SNR              float64 `json:"snr_db"`
+	EffectiveBits    float64 `json:"effective_bits"`
+}
+
+/**
+ * Compares a converted copy against the original samples, both must hold the same number of samples.
+ * Errors are relative to full scale, LSB figures are in units of the original bit depth.
+ */
+func compareFidelity(original, converted []complex64, sampleSize uint32) (FidelityReport, error) {
+	report := FidelityReport{Samples: len(converted), ReferenceSamples: len(original)}
+
+	n := len(original)
+	if n == 0 {
+		return report, errors.New("no samples to compare")
+	}
+	if len(converted) != n {
+		return report, fmt.Errorf("sample count differs: %d, original has %d", len(converted), n)
+	}
+
+	var signal, noise float64
+	for i := 0; i < n; i++ {
+		for _, pair := range [][2]float32{
+			{real(original[i]), real(converted[i])},
+			{imag(original[i]), imag(converted[i])},
+		} {
+			diff := float64(pair[0]) - float64(pair[1])
+			signal += float64(pair[0]) * float64(pair[0])
+			noise += diff * diff
+			report.MaxError = math.Max(report.MaxError, math.Abs(diff))
+		}
+	}
+
+	// the SNR of a silent original is undefined
+	if signal == 0 {
+		return report, errors.New("original samples are silent")
+	}
+
+	report.MaxErrorLSB = report.MaxError * math.Exp2(float64(sampleSize)-1)
+	report.RMSError = math.Sqrt(noise / float64(2*n))
+
+	// lossless copies have an infinite SNR
+	report.SNR = 10 * math.Log10(signal/noise)
+	report.EffectiveBits = (report.SNR - 1.76) / 6.02
+
+	return report, nil
+}
+
+func (r *FidelityReport) String() string {
+	return fmt.Sprintf("Samples: %d (original %d)\n\rMaxError: %g (%.1f LSB)\n\rRMSError: %g\n\rSNR: %.2f dB\n\rEffectiveBits: %.2f",
+		r.Samples, r.ReferenceSamples, r.MaxError, r.MaxErrorLSB, r.RMSError, r.SNR, r.EffectiveBits)
+}
@@ -0,0 +1,54 @@
+//go:build !minimal
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareFidelity(t *testing.T) {
+	original := make([]complex64, 1000)
+	for i := range original {
+		phase := float64(i) * 0.05
+		original[i] = complex(float32(0.5*math.Cos(phase)), float32(0.5*math.Sin(phase)))
+	}
+
+	// a lossless copy
+	report, err := compareFidelity(original, original, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MaxError != 0 || !math.IsInf(report.SNR, 1) {
+		t.Errorf("lossless copy: got %+v", report)
+	}
+
+	// the same samples through the 16-bit WAV path lose the low 8 bits
+	converted := make([]complex64, len(original))
+	for i, s := range original {
+		quantize := func(v float32) float32 { return float32(math.Round(float64(v)*32768)) / 32768 }
+		converted[i] = complex(quantize(real(s)), quantize(imag(s)))
+	}
+	report, err = compareFidelity(original, converted, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MaxErrorLSB > 128 || report.EffectiveBits < 14 || report.EffectiveBits > 17 {
+		t.Errorf("16-bit copy: got %+v", report)
+	}
+}
+
+func TestCompareFidelityEmpty(t *testing.T) {
+	if _, err := compareFidelity(nil, nil, 24); err == nil {
+		t.Error("expected an error without samples")
+	}
+	if _, err := compareFidelity(make([]complex64, 10), nil, 24); err == nil {
+		t.Error("expected an error for an empty copy")
+	}
+	if _, err := compareFidelity(make([]complex64, 10), make([]complex64, 5), 24); err == nil {
+		t.Error("expected an error for a shorter copy")
+	}
+	if _, err := compareFidelity(make([]complex64, 10), make([]complex64, 10), 24); err == nil {
+		t.Error("expected an error for a silent original")
+	}
+}
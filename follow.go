@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// how often a recording that is still being written is checked for new data
+const followPoll = 250 * time.Millisecond
+
+// options that need the whole recording up front
+var followUnsupported = []string{"adsb", "cw", "index", "payload-crc", "fallback", "estimate", "timestamps", "merge"}
+
+/**
+ * Converts a recording while SDRangel is still writing it. New samples are appended to the WAV as they arrive
+ * and its header is kept valid after every chunk; the conversion finishes once the file stops growing for idle,
+ * or on SIGINT/SIGTERM.
+ */
+func follow(input string, output string, idle time.Duration) ([]string, error) {
+	for _, name := range followUnsupported {
+		if config.IsSet(name) {
+			return nil, fmt.Errorf("--%s is not supported with --follow", name)
+		}
+	}
+	if config.GetInt("decimate") > 1 {
+		return nil, errors.New("--decimate is not supported with --follow")
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	// wait for the recording to start
+	last := time.Now()
+	file, err := os.Open(input)
+	for os.IsNotExist(err) && followWait(stop, last, idle) {
+		file, err = os.Open(input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	// wait for the header
+	header := make([]byte, 32)
+	read := 0
+	for read < 32 {
+		n, err := file.Read(header[read:])
+		read += n
+		if n > 0 {
+			last = time.Now()
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+		if read < 32 && !followWait(stop, last, idle) {
+			return nil, errors.New("recording stopped before its header was written")
+		}
+	}
+
+	h := parseHeader(header)
+	if !h.CRCValid {
+		logrus.Info("CRC mismatch")
+	}
+	fmt.Println(h.String())
+
+	err = writeOutput(output+"-info.txt", []byte(h.String()))
+	if err != nil {
+		return nil, fmt.Errorf("error writing file: %w", err)
+	}
+	written := []string{output + "-info.txt"}
+
+	err = writeSidecar(output+"-info.json", &Sidecar{Source: input, Header: h})
+	if err != nil {
+		return nil, fmt.Errorf("error writing file: %w", err)
+	}
+	written = append(written, output+"-info.json")
+
+	out, err := createOutput(output + "-iq.wav")
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %w", err)
+	}
+	defer out.Close()
+	written = append(written, out.Name())
+
+	_, err = out.Write(wavHeader(h.SampleRate, 2, 16))
+	if err != nil {
+		return nil, fmt.Errorf("error writing file: %w", err)
+	}
+
+	// samples go to the file and, optionally, to a live stream, unpaced since they already arrive in real time
+	sink, closeSink, err := featureSink(out, defaultPlan(h).byteRate(), false)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSink()
+
+	var dataSize int64
+	buf := make([]byte, convertChunk*8)
+	pending := 0
+	for {
+		n, err := file.Read(buf[pending:])
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+
+		if n > 0 {
+			last = time.Now()
+			pending += n
+
+			// convert whole 4-byte words (a 24-bit sample or a 16-bit I/Q pair), keep the rest for the next read
+			whole := pending - pending%4
+			m, err := convertTo16BitStream(sink, buf[:whole], h.SampleSize)
+			dataSize += m
+			if err != nil {
+				return nil, fmt.Errorf("error writing file: %w", err)
+			}
+			pending = copy(buf, buf[whole:pending])
+
+			// keep the WAV playable while it grows
+			err = finalizeWav(out, dataSize)
+			if err != nil {
+				return nil, fmt.Errorf("error writing file: %w", err)
+			}
+			continue
+		}
+
+		if !followWait(stop, last, idle) {
+			break
+		}
+	}
+
+	err = finalizeWav(out, dataSize)
+	if err != nil {
+		return nil, fmt.Errorf("error writing file: %w", err)
+	}
+	err = out.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error closing file: %w", err)
+	}
+
+	logrus.WithField("seconds", float64(dataSize/4)/float64(h.SampleRate)).Info("recording finished")
+
+	return written, nil
+}
+
+/**
+ * Waits one poll interval, returns false once the file has been idle too long or a stop signal arrived
+ */
+func followWait(stop chan os.Signal, last time.Time, idle time.Duration) bool {
+	if time.Since(last) >= idle {
+		return false
+	}
+
+	select {
+	case <-stop:
+		return false
+	case <-time.After(followPoll):
+		return true
+	}
+}
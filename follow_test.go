@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixed settings standing in for the parsed flags
+type testSettings map[string]interface{}
+
+func (s testSettings) GetString(key string) string          { v, _ := s[key].(string); return v }
+func (s testSettings) GetBool(key string) bool              { v, _ := s[key].(bool); return v }
+func (s testSettings) GetInt(key string) int                { v, _ := s[key].(int); return v }
+func (s testSettings) GetInt64(key string) int64            { v, _ := s[key].(int64); return v }
+func (s testSettings) GetDuration(key string) time.Duration { v, _ := s[key].(time.Duration); return v }
+
+func (s testSettings) IsSet(key string) bool {
+	_, ok := s[key]
+	return ok
+}
+
+func TestFollow(t *testing.T) {
+	defer func(old settings) { config = old }(config)
+	config = testSettings{}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.sdriq")
+	output := filepath.Join(dir, "raw")
+
+	header := make([]byte, 32)
+	binary.LittleEndian.PutUint32(header[0:], 48000)
+	binary.LittleEndian.PutUint64(header[4:], 7000000)
+	binary.LittleEndian.PutUint32(header[20:], 24)
+	binary.LittleEndian.PutUint32(header[28:], crc32.ChecksumIEEE(header[:28]))
+	payload := make([]byte, 8*1000)
+	rand.New(rand.NewSource(1)).Read(payload)
+
+	// the recorder appends in pieces that split samples
+	recording := append(header, payload...)
+	go func() {
+		file, err := os.Create(input)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		for start := 0; start < len(recording); start += 1234 {
+			end := start + 1234
+			if end > len(recording) {
+				end = len(recording)
+			}
+			file.Write(recording[start:end])
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	written, err := follow(input, output, 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 3 {
+		t.Errorf("wrote %v", written)
+	}
+
+	wav, err := ioutil.ReadFile(output + "-iq.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same samples as converting the finished recording
+	var want bytes.Buffer
+	_, err = convertTo16BitStream(&want, payload, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wav) < 44 || !bytes.Equal(wav[44:], want.Bytes()) {
+		t.Fatalf("got %d sample bytes, want %d", len(wav)-44, want.Len())
+	}
+	if size := binary.LittleEndian.Uint32(wav[40:]); int(size) != want.Len() {
+		t.Errorf("data chunk size %d, want %d", size, want.Len())
+	}
+}
+
+func TestFollowUnsupported(t *testing.T) {
+	defer func(old settings) { config = old }(config)
+
+	for _, s := range []testSettings{{"index": true}, {"timestamps": "csv"}, {"decimate": 2}} {
+		config = s
+		if _, err := follow(filepath.Join(t.TempDir(), "in.sdriq"), "raw", time.Second); err == nil {
+			t.Errorf("%v: expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// index file magic, followed by the version
+const indexMagic = "SDRIDX\x00\x01"
+
+type IndexEntry struct {
+	Offset time.Duration `json:"offset"`
+	Byte   int64         `json:"byte"`
+}
+
+/**
+ * Maps time offsets to byte offsets of the input, one entry per interval.
+ * Samples are fixed size, so only the header and the file size are needed.
+ */
+func buildIndex(h Header, size int64, interval time.Duration) []IndexEntry {
+	frame := int64(frameSize(h.SampleSize))
+	frames := (size - 32) / frame
+
+	var entries []IndexEntry
+	for offset := time.Duration(0); ; offset += interval {
+		sample := int64(offset.Seconds() * float64(h.SampleRate))
+		if sample >= frames && offset > 0 {
+			break
+		}
+		entries = append(entries, IndexEntry{Offset: offset, Byte: 32 + sample*frame})
+	}
+
+	return entries
+}
+
+/**
+ * Writes the index as a compact little-endian file:
+ * magic, sample rate, sample size, start (ms), interval (ms), entry count, then (offset ms, byte offset) pairs
+ */
+func writeIndex(path string, h Header, interval time.Duration, entries []IndexEntry) error {
+	body := make([]byte, 40+len(entries)*16)
+	copy(body, indexMagic)
+	binary.LittleEndian.PutUint32(body[8:], h.SampleRate)
+	binary.LittleEndian.PutUint32(body[12:], h.SampleSize)
+	binary.LittleEndian.PutUint64(body[16:], uint64(h.Timestamp.UnixMilli()))
+	binary.LittleEndian.PutUint64(body[24:], uint64(interval.Milliseconds()))
+	binary.LittleEndian.PutUint64(body[32:], uint64(len(entries)))
+
+	for i, entry := range entries {
+		binary.LittleEndian.PutUint64(body[40+i*16:], uint64(entry.Offset.Milliseconds()))
+		binary.LittleEndian.PutUint64(body[48+i*16:], uint64(entry.Byte))
+	}
+
+	return writeOutput(path, body)
+}
+
+/**
+ * Reads an index written by writeIndex, the header holds the sample rate, sample size and start time
+ */
+func readIndex(path string) (Header, time.Duration, []IndexEntry, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Header{}, 0, nil, err
+	}
+	if len(body) < 40 || string(body[:8]) != indexMagic {
+		return Header{}, 0, nil, errors.New("not a seek index")
+	}
+
+	h := Header{
+		SampleRate: binary.LittleEndian.Uint32(body[8:]),
+		SampleSize: binary.LittleEndian.Uint32(body[12:]),
+		Timestamp:  time.UnixMilli(int64(binary.LittleEndian.Uint64(body[16:]))),
+	}
+	interval := time.Duration(binary.LittleEndian.Uint64(body[24:])) * time.Millisecond
+
+	count := binary.LittleEndian.Uint64(body[32:])
+	if count != uint64(len(body)-40)/16 || (len(body)-40)%16 != 0 {
+		return Header{}, 0, nil, fmt.Errorf("index holds %d bytes of entries, header says %d entries", len(body)-40, count)
+	}
+
+	entries := make([]IndexEntry, count)
+	for i := range entries {
+		entries[i].Offset = time.Duration(binary.LittleEndian.Uint64(body[40+i*16:])) * time.Millisecond
+		entries[i].Byte = int64(binary.LittleEndian.Uint64(body[48+i*16:]))
+	}
+
+	return h, interval, entries, nil
+}
+
+/**
+ * Byte offset of the input sample at offset: the last entry at or before it, then whole frames from there
+ */
+func seekIndex(h Header, entries []IndexEntry, offset time.Duration) int64 {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Offset > offset }) - 1
+	if i < 0 {
+		return 32
+	}
+
+	frames := int64((offset - entries[i].Offset).Seconds() * float64(h.SampleRate))
+	return entries[i].Byte + frames*int64(frameSize(h.SampleSize))
+}
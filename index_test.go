@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildIndex(t *testing.T) {
+	// 2.5 seconds of 24-bit samples at 1000 S/s
+	h := Header{SampleRate: 1000, SampleSize: 24}
+	entries := buildIndex(h, 32+2500*8, time.Second)
+
+	want := []IndexEntry{{0, 32}, {time.Second, 32 + 1000*8}, {2 * time.Second, 32 + 2000*8}}
+	if len(entries) != len(want) {
+		t.Fatalf("got %v, want %v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d: got %v, want %v", i, entries[i], want[i])
+		}
+	}
+
+	// 16-bit frames are half the size, an empty recording still has the first entry
+	h.SampleSize = 16
+	if entries := buildIndex(h, 32+1500*4, 500*time.Millisecond); len(entries) != 3 || entries[2].Byte != 32+1000*4 {
+		t.Errorf("16-bit: got %v", entries)
+	}
+	if entries := buildIndex(h, 32, time.Second); len(entries) != 1 || entries[0].Byte != 32 {
+		t.Errorf("empty: got %v", entries)
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	h := Header{SampleRate: 48000, SampleSize: 16, Timestamp: time.UnixMilli(1600000000123)}
+	entries := []IndexEntry{{0, 32}, {time.Second, 32 + 48000*4}}
+	path := filepath.Join(t.TempDir(), "in.sdriq.idx")
+
+	err := writeIndex(path, h, time.Second, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body) != 40+2*16 || string(body[:8]) != indexMagic {
+		t.Fatalf("bad header % x", body[:8])
+	}
+	for _, field := range []struct {
+		offset int
+		want   uint64
+	}{
+		{8, 48000 | 16<<32},
+		{16, 1600000000123},
+		{24, 1000},
+		{32, 2},
+		{56, 1000},
+		{64, 32 + 48000*4},
+	} {
+		if got := binary.LittleEndian.Uint64(body[field.offset:]); got != field.want {
+			t.Errorf("field at %d: got %d, want %d", field.offset, got, field.want)
+		}
+	}
+}
+
+func TestReadIndex(t *testing.T) {
+	h := Header{SampleRate: 2000, SampleSize: 24, Timestamp: time.UnixMilli(1600000000123)}
+	entries := buildIndex(h, 32+5500*8, 500*time.Millisecond)
+	path := filepath.Join(t.TempDir(), "raw-index.idx")
+
+	err := writeIndex(path, h, 500*time.Millisecond, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, interval, read, err := readIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.SampleRate != h.SampleRate || header.SampleSize != h.SampleSize || !header.Timestamp.Equal(h.Timestamp) || interval != 500*time.Millisecond {
+		t.Errorf("got %+v every %s", header, interval)
+	}
+	if len(read) != len(entries) {
+		t.Fatalf("got %d entries, want %d", len(read), len(entries))
+	}
+	for i := range entries {
+		if read[i] != entries[i] {
+			t.Errorf("entry %d: got %v, want %v", i, read[i], entries[i])
+		}
+	}
+
+	// seeking lands on the sample at the offset, between entries too
+	for _, tt := range []struct {
+		offset time.Duration
+		want   int64
+	}{
+		{0, 32},
+		{500 * time.Millisecond, 32 + 1000*8},
+		{1250 * time.Millisecond, 32 + 2500*8},
+		{-time.Second, 32},
+	} {
+		if got := seekIndex(header, read, tt.offset); got != tt.want {
+			t.Errorf("seek to %s: got byte %d, want %d", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestReadIndexInvalid(t *testing.T) {
+	dir := t.TempDir()
+	h := Header{SampleRate: 1000, SampleSize: 16}
+	path := filepath.Join(dir, "raw-index.idx")
+	err := writeIndex(path, h, time.Second, buildIndex(h, 32+3000*4, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, corrupt := range map[string][]byte{
+		"truncated": body[:len(body)-4],
+		"magic":     append([]byte("NOTANIDX"), body[8:]...),
+		"short":     body[:20],
+	} {
+		err = ioutil.WriteFile(path, corrupt, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := readIndex(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
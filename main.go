@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
-	"github.com/spf13/viper"
 	"hash/crc32"
-	"io/ioutil"
+	"io"
+	"math/bits"
 	"os"
 	"strconv"
 	"time"
@@ -32,37 +32,138 @@ func main() {
 	// flags for input and output files using pFlags
 	var input string
 	var output string
+	var index bool
+	var indexInterval time.Duration
+	var timestamps string
+	var timestampInterval time.Duration
+	var payloadCRC bool
+	var crcChunk int
+	var verify string
+	var batch string
+	var order string
+	var followInput bool
+	var followTimeout time.Duration
+	var mode string
+	var owner string
+	var umask string
 
 	// parse flags
 	flag.StringVar(&input, "input", "", "input file")
 	flag.StringVar(&output, "output", "./raw", "output file")
+	flag.BoolVar(&index, "index", false, "write a seek index next to the input file")
+	flag.DurationVar(&indexInterval, "index-interval", time.Second, "time between seek index entries")
+	flag.StringVar(&timestamps, "timestamps", "", "write a timestamp track for the output samples (csv, sigmf)")
+	flag.DurationVar(&timestampInterval, "timestamp-interval", time.Second, "time between rows of the csv timestamp track")
+	flag.BoolVar(&payloadCRC, "payload-crc", false, "store per-chunk CRCs of the sample payload in the JSON sidecar")
+	flag.IntVar(&crcChunk, "crc-chunk", 0, "payload CRC chunk size in bytes, 0 adapts it to the file size")
+	flag.StringVar(&verify, "verify", "", "check the input against the payload CRCs of a JSON sidecar and exit")
+	flag.StringVar(&batch, "batch", "", "convert every .sdriq in this directory, output is then a directory")
+	flag.StringVar(&order, "order", "", "batch order rules, comma separated (newest, oldest, smallest, largest, freq=<Hz>)")
+	flag.BoolVar(&followInput, "follow", false, "convert the input while it is still being recorded")
+	flag.DurationVar(&followTimeout, "follow-timeout", 10*time.Second, "finish following once the input has not grown for this long")
+	flag.StringVar(&mode, "mode", "", "file mode of the outputs, in octal (e.g. 0640)")
+	flag.StringVar(&owner, "owner", "", "owner of the outputs, as user, user:group or :group, names or numeric ids")
+	flag.StringVar(&umask, "umask", "", "process umask, in octal (e.g. 002)")
+
+	// flags of the network, DSP and analysis features
+	registerFeatureFlags()
 	flag.Parse()
 
-	// input flag is required
-	if input == "" {
+	//bind flags to the configuration
+	bindConfig()
+
+	// input flag is required, unless a batch directory, the server or a manifest check is used
+	if input == "" && batch == "" && config.GetString("serve") == "" && config.GetString("verify-manifest") == "" {
 		logrus.Fatal("input file is required")
 	}
 
-	//bind flags to viper
-	viper.BindPFlag("input", flag.Lookup("input"))
-	viper.BindPFlag("output", flag.Lookup("output"))
+	// check options before any output is written
+	if index && indexInterval <= 0 {
+		logrus.Fatal("index interval must be positive")
+	}
+	if timestamps != "" && timestamps != "csv" && timestamps != "sigmf" {
+		logrus.Fatal("timestamps must be csv or sigmf")
+	}
 
-	// read file in input
-	file, err := os.OpenFile(viper.GetString("input"), os.O_RDONLY, 0644)
-	if err != nil {
-		logrus.WithError(err).Fatal("error opening file")
+	// umask for every file and directory created from here on
+	if config.GetString("umask") != "" {
+		mask, err := strconv.ParseUint(config.GetString("umask"), 8, 32)
+		if err != nil || mask > 0777 {
+			logrus.Fatal("umask must be octal, e.g. 002")
+		}
+		err = setUmask(int(mask))
+		if err != nil {
+			logrus.WithError(err).Fatal("error setting umask")
+		}
 	}
 
-	// read file and save the content in a variable
-	content, err := ioutil.ReadAll(file)
+	// mode and owner of the outputs
+	perms, err := parsePermissions(config.GetString("mode"), config.GetString("owner"))
 	if err != nil {
-		logrus.WithError(err).Fatal("error reading file")
+		logrus.WithError(err).Fatal("error parsing output permissions")
+	}
+	outputPermissions = perms
+
+	// set up the features, a manifest check runs instead of a conversion
+	startFeatures()
+
+	started := time.Now()
+	var inputs, outputs []string
+
+	// verify payload against a previous sidecar
+	if config.GetString("verify") != "" {
+		err := verifyInput(config.GetString("input"), config.GetString("verify"))
+		if err != nil {
+			logrus.WithError(err).Fatal("error verifying file")
+		}
+		logrus.Info("payload verified")
+		os.Exit(0)
+	}
+
+	// serve the web UI, compare a converted copy, convert a whole directory, follow a recording or convert a single file
+	if featureServe() {
+		// the web UI ran instead of a conversion
+	} else if config.GetString("compare") != "" {
+		var err error
+		inputs, outputs, err = featureCompare(config.GetString("input"), config.GetString("compare"), config.GetString("output"))
+		if err != nil {
+			logrus.WithError(err).Fatal("error comparing file")
+		}
+	} else if config.GetString("batch") != "" {
+		var err error
+		inputs, outputs, err = runBatch(config.GetString("batch"), config.GetString("output"), config.GetString("order"))
+		if err != nil {
+			logrus.WithError(err).Fatal("error running batch")
+		}
+	} else if config.GetBool("follow") {
+		var err error
+		outputs, err = follow(config.GetString("input"), config.GetString("output"), config.GetDuration("follow-timeout"))
+		if err != nil {
+			logrus.WithError(err).Fatal("error following file")
+		}
+		inputs = []string{config.GetString("input")}
+	} else {
+		var err error
+		inputs, outputs, err = convert(config.GetString("input"), config.GetString("output"))
+		if err != nil {
+			logrus.WithError(err).Fatal("error converting file")
+		}
 	}
 
-	// extract header, first 32 bytes
-	header := content[:32]
+	// write signed manifest
+	finishFeatures(started, inputs, outputs)
 
-	// fix header slice into Header struct
+	// print success
+	logrus.Info("done")
+
+	// exit
+	os.Exit(0)
+}
+
+/**
+ * Parses the 32-byte .sdriq header
+ */
+func parseHeader(header []byte) Header {
 	var h Header
 	h.SampleRate = binary.LittleEndian.Uint32(header[0:4])
 	h.CenterFreq = binary.LittleEndian.Uint64(header[4:12])
@@ -76,115 +177,64 @@ func main() {
 
 	// check crc
 	h.CRCValid = crc == h.CRC
-	if !h.CRCValid {
-		logrus.Info("CRC mismatch")
-	}
 
 	// convert timestamp to time.Time
 	h.Timestamp = time.UnixMilli(int64(timestamp))
 
-	// print header
-	fmt.Println(h.String())
+	return h
+}
 
-	// write header to human-readable file
-	err = ioutil.WriteFile(viper.GetString("output")+"-info.txt", []byte(h.String()), 0644)
+/**
+ * Reads only the header of an .sdriq file
+ */
+func readHeader(path string) (Header, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		logrus.WithError(err).Fatal("error writing file")
-	}
-
-	sampleRate := h.SampleRate
-	sampleRateCalc := (sampleRate * 16 * 2) / 8
-
-	// sample rate to byte slice
-	sampleRateBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(sampleRateBytes, uint32(sampleRate))
-
-	// sample rate calc to byte slice
-	sampleRateCalcBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(sampleRateCalcBytes, uint32(sampleRateCalc))
-
-	waveHeader := []byte{
-		'R', 'I', 'F', 'F',
-		0, 0, 0, 0,
-		'W', 'A', 'V', 'E',
-		'f', 'm', 't', ' ',
-		16, 0, 0, 0,
-		1, 0,
-		2, 0,
-		sampleRateBytes[0], sampleRateBytes[1], sampleRateBytes[2], sampleRateBytes[3],
-		sampleRateCalcBytes[0], sampleRateCalcBytes[1], sampleRateCalcBytes[2], sampleRateCalcBytes[3],
-		4, 0,
-		16, 0,
-		'd', 'a', 't', 'a',
-		0, 0, 0, 0,
+		return Header{}, err
 	}
+	defer file.Close()
 
-	// write wave header to file
-	body := waveHeader
-
-	//convert body to []int64
-	//var samples = make([]uint32, len(content[32:])/4)
-	startIndex := 32
-	for i := 0; i < len(content[startIndex:])/4; i++ {
-		index := startIndex + (i * 4)
-		sample := int32(binary.LittleEndian.Uint32(content[index:]))
-		sample = sample << 8
-		sample = sample >> 16
-
-		sampleBytes := make([]byte, 2)
-		binary.LittleEndian.PutUint16(sampleBytes, uint16(sample))
-		body = append(body, sampleBytes...)
+	header := make([]byte, 32)
+	_, err = io.ReadFull(file, header)
+	if err != nil {
+		return Header{}, err
 	}
 
-	bodySamples := body[44:]
-	bodySamplesLen := len(bodySamples) / 2
-
-	logrus.Info("body samples length: ", bodySamplesLen%8)
-
-	//body = append(body, convertTo16Bit(binary.LittleEndian.Uint32(content[32:]))...)
-	// calc file size
-	fileSize := len(body) - 8
-
-	// convert file size to byte slice
-	fileSizeBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(fileSizeBytes, uint32(fileSize))
-
-	// write file size to wave header
-	body[4] = fileSizeBytes[0]
-	body[5] = fileSizeBytes[1]
-	body[6] = fileSizeBytes[2]
-	body[7] = fileSizeBytes[3]
-
-	// calc data size
-	dataSize := len(body) - 44
-
-	// convert data size to byte slice
-	dataSizeBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(dataSizeBytes, uint32(dataSize))
-
-	// write data size to wave header
-	body[40] = dataSizeBytes[0]
-	body[41] = dataSizeBytes[1]
-	body[42] = dataSizeBytes[2]
-	body[43] = dataSizeBytes[3]
+	return parseHeader(header), nil
+}
 
-	// write body to file
-	err = ioutil.WriteFile(viper.GetString("output")+"-iq.wav", body, 0644)
-	if err != nil {
-		logrus.WithError(err).Fatal("error writing file")
+/**
+ * Decodes the sample payload into normalized complex samples.
+ * 16-bit recordings store I/Q as int16 pairs, 24-bit recordings as int32 pairs.
+ */
+func decodeIQ(payload []byte, sampleSize uint32) []complex64 {
+	if sampleSize == 16 {
+		samples := make([]complex64, len(payload)/4)
+		for i := range samples {
+			re := int16(binary.LittleEndian.Uint16(payload[i*4:]))
+			im := int16(binary.LittleEndian.Uint16(payload[i*4+2:]))
+			samples[i] = complex(float32(re)/(1<<15), float32(im)/(1<<15))
+		}
+		return samples
 	}
 
-	// close file
-	err = file.Close()
-	if err != nil {
-		logrus.WithError(err).Fatal("error closing file")
+	samples := make([]complex64, len(payload)/8)
+	for i := range samples {
+		re := int32(binary.LittleEndian.Uint32(payload[i*8:]))
+		im := int32(binary.LittleEndian.Uint32(payload[i*8+4:]))
+		samples[i] = complex(float32(re)/(1<<23), float32(im)/(1<<23))
 	}
+	return samples
+}
 
-	// print success
-	logrus.Info("done")
-
-	// exit
-	os.Exit(0)
+/**
+ * Returns the size in bytes of one I/Q sample pair
+ */
+func frameSize(sampleSize uint32) int {
+	if sampleSize == 16 {
+		return 4
+	}
+	return 8
 }
 
 /**
@@ -213,3 +263,13 @@ func convertToByte(samples []int16) []byte {
 
 	return result
 }
+
+/**
+ * Returns the smallest power of two greater than or equal to n
+ */
+func nextPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
@@ -0,0 +1,242 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// returned once everything checks out but no trusted key was given, the signer can be anyone
+var errUnauthenticated = errors.New("UNAUTHENTICATED: signature and files are consistent, but the signer is unknown without --trusted-key")
+
+// record of one run, signed so later changes to it or to the files it lists can be detected
+type Manifest struct {
+	Tool       string                 `json:"tool"`
+	Started    time.Time              `json:"started"`
+	Finished   time.Time              `json:"finished"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Inputs     []ManifestFile         `json:"inputs"`
+	Outputs    []ManifestFile         `json:"outputs"`
+}
+
+// file of a run, the path is relative to the manifest's directory
+type ManifestFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	SHA256   string    `json:"sha256"`
+}
+
+// manifest as written to disk, the signature covers the compact JSON of the manifest
+type SignedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+/**
+ * Hashes and signs the files of a run, then writes the signed manifest
+ */
+func writeManifest(path string, keyPath string, started time.Time, parameters map[string]interface{}, inputs []string, outputs []string) error {
+	key, err := loadSigningKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	manifest := Manifest{Tool: "sdrangelToRaw", Started: started.UTC(), Parameters: parameters}
+	for _, list := range []struct {
+		paths []string
+		files *[]ManifestFile
+	}{{inputs, &manifest.Inputs}, {outputs, &manifest.Outputs}} {
+		for _, p := range list.paths {
+			file, err := hashFile(p)
+			if err != nil {
+				return err
+			}
+
+			// relative to the manifest, so it can be verified from any directory
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			file.Path, err = filepath.Rel(dir, abs)
+			if err != nil {
+				return err
+			}
+			file.Path = filepath.ToSlash(file.Path)
+			*list.files = append(*list.files, file)
+		}
+	}
+	manifest.Finished = time.Now().UTC()
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	signed, err := json.MarshalIndent(SignedManifest{
+		Manifest:  body,
+		PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: hex.EncodeToString(ed25519.Sign(key, body)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = writeOutput(path, signed)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithField("public_key", hex.EncodeToString(key.Public().(ed25519.PublicKey))).Info("manifest signed")
+	return nil
+}
+
+/**
+ * Checks the signature of a manifest and that every file it lists is unchanged.
+ * Anyone can re-sign an edited manifest with their own key, so without a trusted public key
+ * the checks still run but the result is errUnauthenticated.
+ */
+func verifyManifest(path string, trusted ed25519.PublicKey) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var signed SignedManifest
+	err = json.Unmarshal(content, &signed)
+	if err != nil {
+		return err
+	}
+	publicKey, err := hex.DecodeString(signed.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	signature, err := hex.DecodeString(signed.Signature)
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	if trusted != nil && !trusted.Equal(ed25519.PublicKey(publicKey)) {
+		return errors.New("manifest was signed by an untrusted key")
+	}
+
+	// the signature covers the compact form, the file holds it indented
+	var body bytes.Buffer
+	err = json.Compact(&body, signed.Manifest)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, body.Bytes(), signature) {
+		return errors.New("invalid signature")
+	}
+
+	var manifest Manifest
+	err = json.Unmarshal(signed.Manifest, &manifest)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, expected := range append(manifest.Inputs, manifest.Outputs...) {
+		file := filepath.FromSlash(expected.Path)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+
+		actual, err := hashFile(file)
+		if err != nil {
+			logrus.WithError(err).WithField("file", expected.Path).Error("file missing")
+			changed++
+			continue
+		}
+		if actual.SHA256 != expected.SHA256 || actual.Size != expected.Size {
+			logrus.WithField("file", expected.Path).Error("file changed")
+			changed++
+		}
+	}
+	if changed > 0 {
+		return fmt.Errorf("%d files missing or changed", changed)
+	}
+	if trusted == nil {
+		return errUnauthenticated
+	}
+
+	return nil
+}
+
+/**
+ * Loads a PKCS#8 PEM Ed25519 private key, e.g. one made with "openssl genpkey -algorithm ed25519"
+ */
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		return nil, errors.New("--manifest-key is required to sign a manifest")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM data in signing key file")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an Ed25519 key")
+	}
+
+	return key, nil
+}
+
+/**
+ * Size, modification time and SHA-256 of a file
+ */
+func hashFile(path string) (ManifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	return ManifestFile{
+		Path:     path,
+		Size:     info.Size(),
+		Modified: info.ModTime().UTC(),
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
@@ -0,0 +1,103 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/**
+ * Writes a fresh PKCS#8 PEM signing key into dir
+ */
+func testSigningKey(t *testing.T, dir string, name string) (string, ed25519.PublicKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path, public
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.sdriq")
+	output := filepath.Join(dir, "out", "in-iq.wav")
+	os.Mkdir(filepath.Join(dir, "out"), 0755)
+	ioutil.WriteFile(input, []byte("recording"), 0644)
+	ioutil.WriteFile(output, []byte("converted"), 0644)
+
+	keyPath, public := testSigningKey(t, dir, "owner.key")
+	manifest := filepath.Join(dir, "run.json")
+	err := writeManifest(manifest, keyPath, time.Now(), map[string]interface{}{"input": input}, []string{input}, []string{output})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pinned key, verified from another directory
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+	if err := verifyManifest(manifest, public); err != nil {
+		t.Fatalf("pinned key: %v", err)
+	}
+
+	// no pinned key is never a success
+	if err := verifyManifest(manifest, nil); !errors.Is(err, errUnauthenticated) {
+		t.Errorf("no pinned key: got %v", err)
+	}
+
+	// another key
+	_, other := testSigningKey(t, dir, "other.key")
+	if err := verifyManifest(manifest, other); err == nil {
+		t.Error("expected an error for another key")
+	}
+
+	// an edited recording, re-hashed and re-signed by someone else
+	ioutil.WriteFile(input, []byte("edited"), 0644)
+	if err := verifyManifest(manifest, public); err == nil {
+		t.Error("expected an error for an edited input")
+	}
+	forgerKey, forger := testSigningKey(t, dir, "forger.key")
+	err = writeManifest(manifest, forgerKey, time.Now(), nil, []string{input}, []string{output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyManifest(manifest, public); err == nil {
+		t.Error("expected an error for a manifest re-signed by another key")
+	}
+	if err := verifyManifest(manifest, forger); err != nil {
+		t.Errorf("forger's own key: %v", err)
+	}
+}
+
+func TestLoadSigningKeyMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.key")
+	if _, err := loadSigningKey(path); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("a missing key was generated")
+	}
+	if _, err := loadSigningKey(""); err == nil {
+		t.Error("expected an error without a key path")
+	}
+}
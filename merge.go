@@ -0,0 +1,159 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// longest stretch used to refine the alignment by cross-correlation
+const alignWindow = 200 * time.Millisecond
+
+/**
+ * Lines up a second recording with the first: the header timestamps give a coarse offset, cross-correlation
+ * refines it to the sample. A positive offset means the second recording starts later.
+ * The second recording is first translated to the center frequency of the first.
+ */
+func alignDiversity(first Header, a []complex64, second Header, b []complex64) (*DiversityAlignment, error) {
+	if first.SampleRate != second.SampleRate {
+		return nil, fmt.Errorf("sample rates differ: %d vs %d", first.SampleRate, second.SampleRate)
+	}
+
+	align := &DiversityAlignment{FrequencyShift: float64(second.CenterFreq) - float64(first.CenterFreq)}
+	if math.Abs(align.FrequencyShift) >= float64(first.SampleRate) {
+		return nil, errors.New("recordings do not overlap in frequency")
+	}
+
+	offset := second.Timestamp.Sub(first.Timestamp).Seconds() * float64(first.SampleRate)
+	align.TimestampOffset = int(math.Round(offset))
+
+	// refine on the start of the overlap, searching up to half the window either side
+	size := nextPow2(int(alignWindow.Seconds() * float64(first.SampleRate)))
+	startA, startB := overlapStart(align.TimestampOffset)
+	if len(a)-startA < size || len(b)-startB < size {
+		size = nextPow2(minInt(len(a)-startA, len(b)-startB)+1) / 2
+	}
+	if size < 16 {
+		return nil, errors.New("recordings do not overlap in time")
+	}
+
+	shifted := translate(b[startB:startB+size], align.FrequencyShift, first.SampleRate, startB)
+	align.CorrelationLag, align.Correlation = crossCorrelate(a[startA:startA+size], shifted)
+	align.SampleOffset = align.TimestampOffset + align.CorrelationLag
+
+	startA, startB = overlapStart(align.SampleOffset)
+	align.Samples = minInt(len(a)-startA, len(b)-startB)
+	if align.Samples <= 0 {
+		return nil, errors.New("recordings do not overlap in time")
+	}
+
+	return align, nil
+}
+
+/**
+ * Aligned overlap of both recordings, the second translated to the center frequency of the first
+ */
+func mergeDiversity(a []complex64, b []complex64, align *DiversityAlignment, sampleRate uint32) ([]complex64, []complex64) {
+	startA, startB := overlapStart(align.SampleOffset)
+	shifted := translate(b[startB:startB+align.Samples], align.FrequencyShift, sampleRate, startB)
+
+	return a[startA : startA+align.Samples], shifted
+}
+
+/**
+ * Interleaves two I/Q channels, first then second, for a 4-channel I1/Q1/I2/Q2 output
+ */
+func interleave(a []complex64, b []complex64) []complex64 {
+	n := minInt(len(a), len(b))
+	result := make([]complex64, 2*n)
+	for i := 0; i < n; i++ {
+		result[2*i] = a[i]
+		result[2*i+1] = b[i]
+	}
+
+	return result
+}
+
+/**
+ * Size of the 4-channel diversity WAV written for the given number of aligned frames
+ */
+func (p outputPlan) diversitySize(frames int64) int64 {
+	return 44 + frames/int64(p.Decimation)*int64(4*p.BitsPerSample/8)
+}
+
+/**
+ * Moves a signal at baseband offset f in a recording centered shift Hz higher to its offset in the first one.
+ * start keeps the phase continuous with the rest of the recording.
+ */
+func translate(samples []complex64, shift float64, sampleRate uint32, start int) []complex64 {
+	if shift == 0 {
+		return samples
+	}
+
+	step := 2 * math.Pi * shift / float64(sampleRate)
+	result := make([]complex64, len(samples))
+	for i, s := range samples {
+		result[i] = complex64(complex128(s) * cmplx.Rect(1, step*float64(start+i)))
+	}
+
+	return result
+}
+
+/**
+ * Lag of b relative to a maximising |correlation|, within half the length either side, with the normalised peak
+ */
+func crossCorrelate(a []complex64, b []complex64) (int, float64) {
+	n := len(a)
+	size := 2 * nextPow2(n)
+	fa := make([]complex128, size)
+	fb := make([]complex128, size)
+	var energyA, energyB float64
+	for i := 0; i < n; i++ {
+		fa[i] = complex128(a[i])
+		fb[i] = complex128(b[i])
+		energyA += real(fa[i])*real(fa[i]) + imag(fa[i])*imag(fa[i])
+		energyB += real(fb[i])*real(fb[i]) + imag(fb[i])*imag(fb[i])
+	}
+	fft(fa)
+	fft(fb)
+
+	// inverse transform of A·conj(B) through the forward FFT of its conjugate
+	for i := range fa {
+		fa[i] = cmplx.Conj(fa[i] * cmplx.Conj(fb[i]))
+	}
+	fft(fa)
+
+	best, peak := 0, -1.0
+	for lag := -n / 2; lag <= n/2; lag++ {
+		v := cmplx.Abs(fa[(size+lag)%size])
+		if v > peak {
+			best, peak = lag, v
+		}
+	}
+
+	if energyA == 0 || energyB == 0 {
+		return 0, 0
+	}
+	return best, peak / float64(size) / math.Sqrt(energyA*energyB)
+}
+
+/**
+ * First sample of each recording inside the overlap for an offset of the second recording
+ */
+func overlapStart(offset int) (int, int) {
+	if offset >= 0 {
+		return offset, 0
+	}
+	return 0, -offset
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,113 @@
+//go:build !minimal
+
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAlignDiversity(t *testing.T) {
+	const rate = 48000
+	const offset = 517
+	const shift = 1000.0
+
+	random := rand.New(rand.NewSource(1))
+	a := make([]complex64, 30000)
+	for i := range a {
+		a[i] = complex(float32(random.NormFloat64()*0.1), float32(random.NormFloat64()*0.1))
+	}
+
+	// the second recording starts offset samples later and is centered shift Hz higher
+	b := make([]complex64, len(a)-offset)
+	step := -2 * math.Pi * shift / rate
+	for i := range b {
+		b[i] = complex64(complex128(a[i+offset]) * cmplx.Rect(1, step*float64(i)))
+	}
+
+	start := time.Unix(1600000000, 0)
+	first := Header{SampleRate: rate, CenterFreq: 7000000, Timestamp: start}
+	second := Header{SampleRate: rate, CenterFreq: 7000000 + shift, Timestamp: start}
+
+	align, err := alignDiversity(first, a, second, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if align.SampleOffset != offset || align.Correlation < 0.9 || align.Samples != len(b) {
+		t.Fatalf("got %+v", align)
+	}
+
+	// after alignment both channels carry the same signal
+	x, y := mergeDiversity(a, b, align, rate)
+	for i := range x {
+		if d := cmplx.Abs(complex128(x[i] - y[i])); d > 1e-4 {
+			t.Fatalf("sample %d: %v vs %v", i, x[i], y[i])
+		}
+	}
+
+	merged := interleave(x, y)
+	if len(merged) != 2*len(x) || merged[2] != x[1] || merged[3] != y[1] {
+		t.Error("channels not interleaved first then second")
+	}
+
+	// mismatched sample rates cannot be merged
+	second.SampleRate = rate / 2
+	if _, err := alignDiversity(first, a, second, b); err == nil {
+		t.Error("expected an error for different sample rates")
+	}
+}
+
+func TestDiversitySize(t *testing.T) {
+	samples := make([]complex64, 1000)
+	plan := outputPlan{SampleRate: 12000, BitsPerSample: 8, Decimation: 4}
+
+	coefficients, err := decimationFilter(plan.Decimation, "fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := decimate(samples, plan.Decimation, coefficients)
+
+	size, err := writeSamples(discard{}, interleave(x, x), plan.BitsPerSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := plan.diversitySize(int64(len(samples))); 44+size != want {
+		t.Errorf("wrote %d bytes, planned %d", 44+size, want)
+	}
+}
+
+func TestPlanFallbackCountsDiversity(t *testing.T) {
+	dir := os.TempDir()
+	free, err := freeSpace(dir)
+	if err != nil || free < 1<<20 {
+		t.Skip("free space unknown")
+	}
+
+	// the main output fits on its own, with the diversity output only at 8 bits
+	h := Header{SampleRate: 48000, SampleSize: 24}
+	plan := defaultPlan(h)
+	frames := free / 64
+	minFree := free - plan.size(frames) - plan.diversitySize(frames)/2
+
+	if _, fallback, err := planFallback(h, plan, frames, 0, dir, minFree, "8bit"); err != nil || fallback != nil {
+		t.Errorf("without diversity: got %+v, %v", fallback, err)
+	}
+
+	planned, fallback, err := planFallback(h, plan, frames, frames, dir, minFree, "8bit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback == nil || planned.BitsPerSample != 8 || fallback.RequiredBytes != plan.size(frames)+plan.diversitySize(frames) {
+		t.Errorf("with diversity: got %+v, %+v", planned, fallback)
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// mode and ownership given to every output file, nil leaves them as created
+var outputPermissions *permissions
+
+type permissions struct {
+	mode *os.FileMode
+	uid  int
+	gid  int
+}
+
+/**
+ * Parses --mode (octal) and --owner (user, user:group or :group), returns nil when neither is set
+ */
+func parsePermissions(mode string, owner string) (*permissions, error) {
+	if mode == "" && owner == "" {
+		return nil, nil
+	}
+
+	p := &permissions{uid: -1, gid: -1}
+	if mode != "" {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || m > 0777 {
+			return nil, fmt.Errorf("invalid mode %q", mode)
+		}
+		fileMode := os.FileMode(m)
+		p.mode = &fileMode
+	}
+
+	if owner != "" {
+		name, group, _ := strings.Cut(owner, ":")
+		if name != "" {
+			uid, err := lookupUser(name)
+			if err != nil {
+				return nil, err
+			}
+			p.uid = uid
+		}
+		if group != "" {
+			gid, err := lookupGroup(group)
+			if err != nil {
+				return nil, err
+			}
+			p.gid = gid
+		}
+	}
+
+	return p, nil
+}
+
+/**
+ * Returns the uid of a user name, a number is taken as the uid itself as it may have no passwd entry
+ */
+func lookupUser(name string) (int, error) {
+	if id, err := strconv.ParseUint(name, 10, 31); err == nil {
+		return int(id), nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+/**
+ * Returns the gid of a group name, a number is taken as the gid itself as it may have no group entry
+ */
+func lookupGroup(name string) (int, error) {
+	if id, err := strconv.ParseUint(name, 10, 31); err == nil {
+		return int(id), nil
+	}
+
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+/**
+ * Creates an output file with the configured mode and ownership already applied,
+ * so readers see the right permissions while it is being written
+ */
+func createOutput(path string) (*os.File, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setPermissions(path, false)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+/**
+ * Writes a whole output file, see createOutput
+ */
+func writeOutput(path string, content []byte) error {
+	file, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+/**
+ * Creates an output directory with the configured mode and ownership
+ */
+func mkdirOutput(path string) error {
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+
+	return setPermissions(path, true)
+}
+
+/**
+ * Applies the configured mode and ownership to one path. Directories are also searchable wherever
+ * the mode lets them be read, so 0640 becomes 0750.
+ */
+func setPermissions(path string, dir bool) error {
+	p := outputPermissions
+	if p == nil {
+		return nil
+	}
+
+	if p.mode != nil {
+		mode := *p.mode
+		if dir {
+			mode |= mode & 0444 >> 2
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if p.uid >= 0 || p.gid >= 0 {
+		if err := os.Chown(path, p.uid, p.gid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParsePermissions(t *testing.T) {
+	if p, err := parsePermissions("", ""); p != nil || err != nil {
+		t.Errorf("no flags: got %v, %v", p, err)
+	}
+	for _, mode := range []string{"999", "abc", "1777"} {
+		if _, err := parsePermissions(mode, ""); err == nil {
+			t.Errorf("%q: expected an error", mode)
+		}
+	}
+
+	p, err := parsePermissions("0640", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p.mode != 0640 || p.uid != -1 || p.gid != -1 {
+		t.Errorf("got mode %o, uid %d, gid %d", *p.mode, p.uid, p.gid)
+	}
+
+	// numeric ids need no passwd or group entry
+	p, err = parsePermissions("", "54321:54322")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.mode != nil || p.uid != 54321 || p.gid != 54322 {
+		t.Errorf("got uid %d, gid %d", p.uid, p.gid)
+	}
+	p, err = parsePermissions("", ":54322")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.uid != -1 || p.gid != 54322 {
+		t.Errorf("got uid %d, gid %d", p.uid, p.gid)
+	}
+}
+
+func TestOutputPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX modes")
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	p, err := parsePermissions("0640", current.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputPermissions = p
+	defer func() { outputPermissions = nil }()
+
+	dir := filepath.Join(t.TempDir(), "out")
+	err = mkdirOutput(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the mode is in place while the file is still open for writing
+	file, err := createOutput(filepath.Join(dir, "a-iq.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	err = writeOutput(filepath.Join(dir, "a-info.txt"), []byte("info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		dir:                              0750,
+		filepath.Join(dir, "a-iq.wav"):   0640,
+		filepath.Join(dir, "a-info.txt"): 0640,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s: got %o, want %o", path, info.Mode().Perm(), want)
+		}
+	}
+}
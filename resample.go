@@ -0,0 +1,194 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// filter length and window trade-off for decimation
+type filterQuality struct {
+	TapsPerFactor int
+	Window        func(i, n int) float64
+}
+
+var filterQualities = map[string]filterQuality{
+	// about 50 dB stop-band
+	"fast": {TapsPerFactor: 24, Window: hammingWindow},
+	// about 75 dB stop-band
+	"medium": {TapsPerFactor: 40, Window: blackmanWindow},
+	// about 100 dB stop-band
+	"best": {TapsPerFactor: 48, Window: kaiserWindow(10)},
+}
+
+// measured characteristics of a decimation filter
+type FilterEstimate struct {
+	Quality    string
+	Taps       int
+	StopBand   float64
+	Ripple     float64
+	Throughput float64
+}
+
+/**
+ * Designs a windowed sinc low-pass, cutoff is relative to the sample rate (0 to 0.5)
+ */
+func designLowpass(cutoff float64, taps int, window func(i, n int) float64) []float64 {
+	coefficients := make([]float64, taps)
+	center := float64(taps-1) / 2
+
+	var sum float64
+	for i := range coefficients {
+		x := float64(i) - center
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		coefficients[i] = sinc * window(i, taps)
+		sum += coefficients[i]
+	}
+
+	// unity gain at DC
+	for i := range coefficients {
+		coefficients[i] /= sum
+	}
+
+	return coefficients
+}
+
+/**
+ * Low-pass filters and keeps every factor-th sample, only the kept outputs are computed
+ */
+func decimate(samples []complex64, factor int, coefficients []float64) []complex64 {
+	if factor <= 1 {
+		return samples
+	}
+
+	delay := len(coefficients) / 2
+	result := make([]complex64, len(samples)/factor)
+	for n := range result {
+		// center the filter on the kept sample so the output is not delayed
+		center := n*factor + delay
+		var re, im float64
+		for k, c := range coefficients {
+			i := center - k
+			if i < 0 || i >= len(samples) {
+				continue
+			}
+			re += c * float64(real(samples[i]))
+			im += c * float64(imag(samples[i]))
+		}
+		result[n] = complex(float32(re), float32(im))
+	}
+
+	return result
+}
+
+/**
+ * Decimation filter of the given quality. The transition band runs from 0.4 to 0.55 of the new sample rate,
+ * so the band kept is alias free down to the stop-band attenuation.
+ */
+func decimationFilter(factor int, quality string) ([]float64, error) {
+	q, ok := filterQualities[quality]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter quality %q, available: %s", quality, strings.Join(filterQualityNames(), ", "))
+	}
+
+	return designLowpass(0.475/float64(factor), q.TapsPerFactor*factor+1, q.Window), nil
+}
+
+/**
+ * Quality names from the shortest filter to the longest
+ */
+func filterQualityNames() []string {
+	var names []string
+	for name := range filterQualities {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return filterQualities[names[i]].TapsPerFactor < filterQualities[names[j]].TapsPerFactor
+	})
+
+	return names
+}
+
+/**
+ * Measures every quality tier at a decimation factor: stop-band attenuation and pass-band ripple
+ * from the filter's frequency response, throughput by decimating the given samples
+ */
+func estimateFilters(factor int, samples []complex64) []FilterEstimate {
+	var result []FilterEstimate
+	for _, name := range filterQualityNames() {
+		coefficients, _ := decimationFilter(factor, name)
+
+		// frequency response on a dense grid
+		size := nextPow2(len(coefficients) * 64)
+		response := make([]complex128, size)
+		for i, c := range coefficients {
+			response[i] = complex(c, 0)
+		}
+		fft(response)
+
+		estimate := FilterEstimate{Quality: name, Taps: len(coefficients), StopBand: math.Inf(1)}
+		for i := 0; i <= size/2; i++ {
+			f := float64(i) / float64(size)
+			gain := 20 * math.Log10(math.Max(math.Hypot(real(response[i]), imag(response[i])), 1e-12))
+			switch {
+			case f <= 0.4/float64(factor):
+				estimate.Ripple = math.Max(estimate.Ripple, math.Abs(gain))
+			case f >= 0.55/float64(factor):
+				// anything above this folds back into the pass-band
+				estimate.StopBand = math.Min(estimate.StopBand, -gain)
+			}
+		}
+
+		start := time.Now()
+		decimate(samples, factor, coefficients)
+		if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+			estimate.Throughput = float64(len(samples)) / elapsed
+		}
+
+		result = append(result, estimate)
+	}
+
+	return result
+}
+
+func hammingWindow(i, n int) float64 {
+	return 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+}
+
+func blackmanWindow(i, n int) float64 {
+	x := 2 * math.Pi * float64(i) / float64(n-1)
+	return 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+}
+
+/**
+ * Kaiser window, beta sets the side-lobe level
+ */
+func kaiserWindow(beta float64) func(i, n int) float64 {
+	return func(i, n int) float64 {
+		r := 2*float64(i)/float64(n-1) - 1
+		return besselI0(beta*math.Sqrt(1-r*r)) / besselI0(beta)
+	}
+}
+
+/**
+ * Modified Bessel function of the first kind, order zero, by its power series
+ */
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-16 {
+			break
+		}
+	}
+
+	return sum
+}
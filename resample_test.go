@@ -0,0 +1,100 @@
+//go:build !minimal
+
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestEstimateFilters(t *testing.T) {
+	// stop-band floors of the quality tiers, from their comments
+	want := map[string]float64{"fast": 50, "medium": 74, "best": 99}
+
+	for _, factor := range []int{2, 4, 8} {
+		estimates := estimateFilters(factor, nil)
+		if len(estimates) != 3 || estimates[0].Quality != "fast" || estimates[2].Quality != "best" {
+			t.Fatalf("factor %d: got %+v", factor, estimates)
+		}
+
+		for _, e := range estimates {
+			if e.StopBand < want[e.Quality] {
+				t.Errorf("factor %d, %s: stop-band %.1f dB, want at least %.0f", factor, e.Quality, e.StopBand, want[e.Quality])
+			}
+			if e.Ripple > 0.05 {
+				t.Errorf("factor %d, %s: pass-band ripple %.3f dB", factor, e.Quality, e.Ripple)
+			}
+		}
+	}
+}
+
+func TestDecimate(t *testing.T) {
+	const rate = 48000
+	const factor = 4
+
+	coefficients, err := decimationFilter(factor, "medium")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// amplitude of a decimated tone, away from the edges where the filter runs off the samples
+	level := func(freq float64) float64 {
+		samples := make([]complex64, 48000)
+		for i := range samples {
+			samples[i] = complex64(cmplx.Rect(0.5, 2*math.Pi*freq*float64(i)/rate))
+		}
+
+		result := decimate(samples, factor, coefficients)
+		if len(result) != len(samples)/factor {
+			t.Fatalf("got %d samples, want %d", len(result), len(samples)/factor)
+		}
+
+		var peak float64
+		edge := len(coefficients) / factor
+		for _, v := range result[edge : len(result)-edge] {
+			peak = math.Max(peak, cmplx.Abs(complex128(v)))
+		}
+		return peak
+	}
+
+	// a tone in the kept band passes, one that would alias into it is removed
+	if got := level(2000); math.Abs(got-0.5) > 0.001 {
+		t.Errorf("2 kHz tone at %.4f, want 0.5", got)
+	}
+	if got := 20 * math.Log10(level(9000)/0.5); got > -70 {
+		t.Errorf("9 kHz tone at %.1f dB, want below -70", got)
+	}
+
+	// the output lines up with the input, the filter adds no delay
+	samples := make([]complex64, 1000)
+	samples[400] = 1
+	result := decimate(samples, factor, coefficients)
+	peak := 0
+	for i := range result {
+		if cmplx.Abs(complex128(result[i])) > cmplx.Abs(complex128(result[peak])) {
+			peak = i
+		}
+	}
+	if peak != 400/factor {
+		t.Errorf("impulse at output sample %d, want %d", peak, 400/factor)
+	}
+}
+
+func TestDecimationFilterQuality(t *testing.T) {
+	if _, err := decimationFilter(2, "ultra"); err == nil {
+		t.Error("expected an error for an unknown quality")
+	}
+
+	coefficients, err := decimationFilter(3, "best")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, c := range coefficients {
+		sum += c
+	}
+	if len(coefficients) != 48*3+1 || math.Abs(sum-1) > 1e-12 {
+		t.Errorf("got %d taps with DC gain %g", len(coefficients), sum)
+	}
+}
@@ -0,0 +1,219 @@
+//go:build !minimal
+
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"image/png"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// thumbnail size in pixels
+const thumbnailWidth = 256
+const thumbnailHeight = 128
+
+//go:embed web
+var webFiles embed.FS
+
+type server struct {
+	recordings string
+	output     string
+
+	// only loopback host names are served when listening on loopback
+	loopback bool
+
+	// conversions are heavy, run them one at a time
+	convertMutex sync.Mutex
+}
+
+type recordingInfo struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Header Header `json:"header"`
+}
+
+/**
+ * Serves the web UI and its API: recordings of a directory, their thumbnails, conversion and download
+ */
+func serve(address string, recordings string, output string) error {
+	err := mkdirOutput(output)
+	if err != nil {
+		return err
+	}
+
+	address = listenAddress(address)
+	host, _, _ := net.SplitHostPort(address)
+	ip := net.ParseIP(host)
+
+	s := &server{recordings: recordings, output: output, loopback: host == "localhost" || ip != nil && ip.IsLoopback()}
+	web, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(web)))
+	mux.HandleFunc("/api/recordings", s.sameOrigin(s.handleRecordings))
+	mux.HandleFunc("/api/thumbnail", s.sameOrigin(s.handleThumbnail))
+	mux.HandleFunc("/api/convert", s.sameOrigin(s.handleConvert))
+	mux.HandleFunc("/api/download", s.sameOrigin(s.handleDownload))
+
+	logrus.WithField("address", address).Info("serving")
+	return http.ListenAndServe(address, mux)
+}
+
+/**
+ * Listens on localhost unless the address names a host, "8080" and ":8080" both become "127.0.0.1:8080"
+ */
+func listenAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = "", address
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+/**
+ * Rejects API requests made by other sites, so a page the operator visits cannot start conversions.
+ * Browsers send Sec-Fetch-Site and Origin; requests without them, e.g. from curl, are allowed.
+ */
+func (s *server) sameOrigin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				http.Error(w, "cross-origin request", http.StatusForbidden)
+				return
+			}
+		}
+
+		// a rebound DNS name reaching a loopback server is same-origin for the browser
+		if s.loopback {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			ip := net.ParseIP(strings.Trim(host, "[]"))
+			if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				http.Error(w, "unexpected host", http.StatusForbidden)
+				return
+			}
+		}
+
+		handler(w, r)
+	}
+}
+
+func (s *server) handleRecordings(w http.ResponseWriter, r *http.Request) {
+	jobs, err := listBatch(s.recordings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := []recordingInfo{}
+	for _, job := range jobs {
+		result = append(result, recordingInfo{Name: filepath.Base(job.Path), Size: job.Size, Header: job.Header})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	path, err := s.recording(r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	img, err := spectrogramImage(path, thumbnailWidth, thumbnailHeight)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, img)
+}
+
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path, err := s.recording(r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.convertMutex.Lock()
+	defer s.convertMutex.Unlock()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	_, written, err := convert(path, filepath.Join(s.output, name))
+	if err != nil {
+		logrus.WithError(err).WithField("file", path).Error("error converting file")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// downloadable files written for this recording
+	files := []string{}
+	for _, p := range written {
+		if filepath.Dir(p) == filepath.Clean(s.output) {
+			files = append(files, filepath.Base(p))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+func (s *server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Base(r.URL.Query().Get("file"))
+	path := filepath.Join(s.output, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	http.ServeFile(w, r, path)
+}
+
+/**
+ * Resolves a recording name to its path, names cannot leave the recordings directory
+ */
+func (s *server) recording(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || !strings.EqualFold(filepath.Ext(name), ".sdriq") {
+		return "", errors.New("invalid recording name")
+	}
+
+	path := filepath.Join(s.recordings, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.New("recording not found")
+	}
+
+	return path, nil
+}
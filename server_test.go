@@ -0,0 +1,57 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListenAddress(t *testing.T) {
+	tests := map[string]string{
+		":8080":        "127.0.0.1:8080",
+		"8080":         "127.0.0.1:8080",
+		"0.0.0.0:8080": "0.0.0.0:8080",
+		"[::1]:8080":   "[::1]:8080",
+	}
+
+	for address, want := range tests {
+		if got := listenAddress(address); got != want {
+			t.Errorf("%q: got %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	s := &server{loopback: true}
+	handler := s.sameOrigin(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		host    string
+		headers map[string]string
+		status  int
+	}{
+		{"127.0.0.1:8080", nil, http.StatusOK},
+		{"localhost:8080", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://localhost:8080"}, http.StatusOK},
+		{"127.0.0.1:8080", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"127.0.0.1:8080", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+		{"127.0.0.1:8080", map[string]string{"Origin": "http://example.com"}, http.StatusForbidden},
+		// DNS rebinding: the page's own name resolved to loopback
+		{"attacker.example:8080", map[string]string{"Origin": "http://attacker.example:8080"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/convert?name=x.sdriq", nil)
+		r.Host = tt.host
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %v: got %d, want %d", tt.host, tt.headers, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"hash/crc32"
+	"io/ioutil"
+)
+
+// bounds of the adaptive payload checksum chunk size
+const minCRCChunk = 64 << 10
+const maxCRCChunk = 64 << 20
+
+// chunk count the adaptive chunk size aims for
+const targetCRCChunks = 1024
+
+type Sidecar struct {
+	Source           string              `json:"source"`
+	Header           Header              `json:"header"`
+	PayloadChecksums *PayloadChecksums   `json:"payload_checksums,omitempty"`
+	Fallback         *Fallback           `json:"fallback,omitempty"`
+	Diversity        *DiversityAlignment `json:"diversity,omitempty"`
+}
+
+type PayloadChecksums struct {
+	Algorithm string   `json:"algorithm"`
+	ChunkSize int      `json:"chunk_size"`
+	Size      int64    `json:"size"`
+	Chunks    []uint32 `json:"chunks"`
+}
+
+// substitution made because the output would not fit, recorded in the sidecar
+type Fallback struct {
+	Reason        string `json:"reason"`
+	FreeBytes     int64  `json:"free_bytes"`
+	RequiredBytes int64  `json:"required_bytes"`
+	BitsPerSample int    `json:"bits_per_sample"`
+	Decimation    int    `json:"decimation"`
+	SampleRate    uint32 `json:"sample_rate"`
+}
+
+// how two recordings of the same band were lined up, recorded in the sidecar
+type DiversityAlignment struct {
+	Second          string  `json:"second"`
+	TimestampOffset int     `json:"timestamp_offset"`
+	CorrelationLag  int     `json:"correlation_lag"`
+	SampleOffset    int     `json:"sample_offset"`
+	Correlation     float64 `json:"correlation"`
+	FrequencyShift  float64 `json:"frequency_shift_hz"`
+	Samples         int     `json:"samples"`
+}
+
+// payload chunk whose checksum no longer matches
+type ChunkMismatch struct {
+	Chunk int
+	Start int64
+	End   int64
+}
+
+/**
+ * Picks a power-of-two chunk size giving about targetCRCChunks chunks
+ */
+func adaptiveCRCChunk(size int64) int {
+	chunk := nextPow2(int(size / targetCRCChunks))
+	if chunk < minCRCChunk {
+		return minCRCChunk
+	}
+	if chunk > maxCRCChunk {
+		return maxCRCChunk
+	}
+	return chunk
+}
+
+/**
+ * Computes the CRC-32 of every chunk of the sample payload
+ */
+func payloadChecksums(payload []byte, chunkSize int) *PayloadChecksums {
+	checksums := &PayloadChecksums{Algorithm: "crc32-ieee", ChunkSize: chunkSize, Size: int64(len(payload))}
+	for start := 0; start < len(payload); start += chunkSize {
+		end := start + chunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		checksums.Chunks = append(checksums.Chunks, crc32.ChecksumIEEE(payload[start:end]))
+	}
+
+	return checksums
+}
+
+/**
+ * Compares the payload against stored checksums, returns the chunks that changed
+ */
+func verifyPayload(payload []byte, stored *PayloadChecksums) ([]ChunkMismatch, error) {
+	if stored.Algorithm != "crc32-ieee" {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", stored.Algorithm)
+	}
+	if int64(len(payload)) != stored.Size {
+		return nil, fmt.Errorf("payload size changed: %d, expected %d", len(payload), stored.Size)
+	}
+
+	current := payloadChecksums(payload, stored.ChunkSize)
+	if len(current.Chunks) != len(stored.Chunks) {
+		return nil, errors.New("chunk count does not match payload size")
+	}
+
+	var mismatches []ChunkMismatch
+	for i, crc := range current.Chunks {
+		if crc != stored.Chunks[i] {
+			start := int64(i) * int64(stored.ChunkSize)
+			end := start + int64(stored.ChunkSize)
+			if end > stored.Size {
+				end = stored.Size
+			}
+			mismatches = append(mismatches, ChunkMismatch{Chunk: i, Start: start, End: end})
+		}
+	}
+
+	return mismatches, nil
+}
+
+/**
+ * Checks an input recording against the payload checksums of its sidecar, logging every changed chunk
+ */
+func verifyInput(input string, sidecarPath string) error {
+	sidecar, err := readSidecar(sidecarPath)
+	if err != nil {
+		return fmt.Errorf("error reading sidecar: %w", err)
+	}
+	if sidecar.PayloadChecksums == nil {
+		return errors.New("sidecar has no payload checksums")
+	}
+
+	content, err := ioutil.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	if len(content) < 32 {
+		return errors.New("input file is too short")
+	}
+	h := parseHeader(content[:32])
+
+	mismatches, err := verifyPayload(content[32:], sidecar.PayloadChecksums)
+	if err != nil {
+		return fmt.Errorf("error verifying payload: %w", err)
+	}
+
+	frame := int64(frameSize(h.SampleSize)) * int64(h.SampleRate)
+	for _, m := range mismatches {
+		logrus.WithFields(logrus.Fields{
+			"chunk":  m.Chunk,
+			"bytes":  fmt.Sprintf("%d-%d", 32+m.Start, 32+m.End),
+			"offset": fmt.Sprintf("%.3fs-%.3fs", float64(m.Start)/float64(frame), float64(m.End)/float64(frame)),
+		}).Error("payload chunk changed")
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d of %d chunks changed", len(mismatches), len(sidecar.PayloadChecksums.Chunks))
+	}
+
+	return nil
+}
+
+/**
+ * Reads a JSON sidecar written by a previous conversion
+ */
+func readSidecar(path string) (*Sidecar, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sidecar Sidecar
+	err = json.Unmarshal(content, &sidecar)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sidecar, nil
+}
+
+/**
+ * Writes the JSON sidecar
+ */
+func writeSidecar(path string, sidecar *Sidecar) error {
+	content, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(path, content)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyPayload(t *testing.T) {
+	payload := make([]byte, 3*minCRCChunk+1000)
+	rand.New(rand.NewSource(1)).Read(payload)
+
+	stored := payloadChecksums(payload, minCRCChunk)
+	if len(stored.Chunks) != 4 || stored.Size != int64(len(payload)) {
+		t.Fatalf("got %d chunks of %d bytes", len(stored.Chunks), stored.Size)
+	}
+
+	mismatches, err := verifyPayload(payload, stored)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("unchanged payload: %v, %v", mismatches, err)
+	}
+
+	// one flipped bit in the second chunk and one in the short last chunk
+	payload[minCRCChunk+10] ^= 1
+	payload[len(payload)-1] ^= 0x80
+	mismatches, err = verifyPayload(payload, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChunkMismatch{
+		{Chunk: 1, Start: minCRCChunk, End: 2 * minCRCChunk},
+		{Chunk: 3, Start: 3 * minCRCChunk, End: int64(len(payload))},
+	}
+	if len(mismatches) != len(want) || mismatches[0] != want[0] || mismatches[1] != want[1] {
+		t.Errorf("got %v, want %v", mismatches, want)
+	}
+
+	// a truncated payload or an unknown algorithm cannot be checked chunk by chunk
+	if _, err := verifyPayload(payload[:len(payload)-8], stored); err == nil {
+		t.Error("expected an error for a truncated payload")
+	}
+	stored.Algorithm = "md5"
+	if _, err := verifyPayload(payload, stored); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
+
+func TestAdaptiveCRCChunk(t *testing.T) {
+	tests := []struct {
+		size int64
+		want int
+	}{
+		{0, minCRCChunk},
+		{1 << 20, minCRCChunk},
+		{1 << 30, 1 << 20},
+		{1<<30 + 1024, 2 << 20},
+		{1 << 40, maxCRCChunk},
+	}
+
+	for _, tt := range tests {
+		if got := adaptiveCRCChunk(tt.size); got != tt.want {
+			t.Errorf("size %d: got %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyInput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.sdriq")
+	sidecar := filepath.Join(dir, "raw-info.json")
+
+	content := make([]byte, 32+4*48000)
+	rand.New(rand.NewSource(1)).Read(content[32:])
+	err := ioutil.WriteFile(input, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeSidecar(sidecar, &Sidecar{Source: input, PayloadChecksums: payloadChecksums(content[32:], minCRCChunk)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyInput(input, sidecar); err != nil {
+		t.Errorf("unchanged input: %v", err)
+	}
+
+	content[32+minCRCChunk] ^= 1
+	err = ioutil.WriteFile(input, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyInput(input, sidecar); err == nil {
+		t.Error("expected an error for a changed input")
+	}
+}
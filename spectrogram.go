@@ -0,0 +1,102 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+/**
+ * Renders a spectrogram thumbnail, time runs left to right and frequency bottom to top.
+ * Only width short segments spread over the recording are read, so large files stay cheap.
+ */
+func spectrogramImage(path string, width int, height int) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 32)
+	_, err = io.ReadFull(file, header)
+	if err != nil {
+		return nil, err
+	}
+	h := parseHeader(header)
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	frame := int64(frameSize(h.SampleSize))
+	frames := (info.Size() - 32) / frame
+
+	size := nextPow2(height)
+	if frames < int64(size) {
+		return nil, errors.New("recording too short for a spectrogram")
+	}
+
+	// power in dB of every column
+	columns := make([][]float64, width)
+	raw := make([]byte, int64(size)*frame)
+	buf := make([]complex128, size)
+	var levels []float64
+	for x := range columns {
+		offset := 32 + int64(x)*(frames-int64(size))/int64(width)*frame
+		_, err = file.ReadAt(raw, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, s := range decodeIQ(raw, h.SampleSize) {
+			// hann window
+			w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+			buf[i] = complex128(s) * complex(w, 0)
+		}
+		fft(buf)
+
+		columns[x] = make([]float64, size)
+		for i, v := range buf {
+			// center frequency in the middle
+			bin := (i + size/2) % size
+			columns[x][bin] = 10 * math.Log10(real(v)*real(v)+imag(v)*imag(v)+1e-20)
+			levels = append(levels, columns[x][bin])
+		}
+	}
+
+	// median noise floor at the bottom of the colour map
+	sort.Float64s(levels)
+	low, high := levels[len(levels)/2], levels[len(levels)-1]
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x, column := range columns {
+		for y := 0; y < height; y++ {
+			bin := (height - 1 - y) * size / height
+			img.Set(x, y, heatColor((column[bin]-low)/(high-low+1e-9)))
+		}
+	}
+
+	return img, nil
+}
+
+/**
+ * Maps 0..1 to a black, blue, yellow, white colour ramp
+ */
+func heatColor(v float64) color.RGBA {
+	v = math.Max(0, math.Min(1, v))
+	switch {
+	case v < 1.0/3:
+		return color.RGBA{B: uint8(v * 3 * 255), A: 255}
+	case v < 2.0/3:
+		t := (v - 1.0/3) * 3
+		return color.RGBA{R: uint8(t * 255), G: uint8(t * 255), B: uint8((1 - t) * 255), A: 255}
+	}
+	t := (v - 2.0/3) * 3
+	return color.RGBA{R: 255, G: 255, B: uint8(t * 255), A: 255}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// contiguous run of output samples starting at a known absolute time
+type TimeSegment struct {
+	SampleStart int64     `json:"sample_start"`
+	Samples     int64     `json:"samples"`
+	Time        time.Time `json:"time"`
+}
+
+/**
+ * Absolute time of an output sample, interpolated from the segment it belongs to
+ */
+func sampleTime(segments []TimeSegment, sampleRate uint32, sample int64) time.Time {
+	segment := segments[0]
+	for _, s := range segments {
+		if s.SampleStart <= sample {
+			segment = s
+		}
+	}
+
+	offset := float64(sample-segment.SampleStart) / float64(sampleRate)
+	return segment.Time.Add(time.Duration(offset * float64(time.Second)))
+}
+
+/**
+ * Timestamp track as CSV, one row at the start of every segment and every interval within it
+ */
+func formatTimestampsCSV(segments []TimeSegment, sampleRate uint32, interval time.Duration) []byte {
+	step := int64(interval.Seconds() * float64(sampleRate))
+	if step < 1 {
+		step = 1
+	}
+
+	var result strings.Builder
+	result.WriteString("sample,unix_ns,time\n")
+	for _, segment := range segments {
+		for sample := segment.SampleStart; sample < segment.SampleStart+segment.Samples; sample += step {
+			t := sampleTime(segments, sampleRate, sample)
+			fmt.Fprintf(&result, "%d,%d,%s\n", sample, t.UnixNano(), t.UTC().Format(time.RFC3339Nano))
+		}
+	}
+
+	return []byte(result.String())
+}
+
+/**
+ * Timestamp track as a SigMF metadata file, one capture segment per time segment.
+ * The WAV is described as a non-conforming dataset whose samples follow a 44-byte header.
+ */
+func formatTimestampsSigMF(segments []TimeSegment, h Header, plan outputPlan, dataset string) ([]byte, error) {
+	datatype := "ci16_le"
+	if plan.BitsPerSample == 8 {
+		datatype = "cu8"
+	}
+
+	var captures []map[string]interface{}
+	for _, segment := range segments {
+		capture := map[string]interface{}{
+			"core:sample_start": segment.SampleStart,
+			"core:frequency":    h.CenterFreq,
+			"core:datetime":     segment.Time.UTC().Format(time.RFC3339Nano),
+		}
+		if len(captures) == 0 {
+			capture["core:header_bytes"] = 44
+		}
+		captures = append(captures, capture)
+	}
+
+	meta := map[string]interface{}{
+		"global": map[string]interface{}{
+			"core:datatype":    datatype,
+			"core:sample_rate": plan.SampleRate,
+			"core:version":     "1.0.0",
+			"core:dataset":     filepath.Base(dataset),
+			"core:recorder":    "SDRangel",
+		},
+		"captures":    captures,
+		"annotations": []interface{}{},
+	}
+
+	return json.MarshalIndent(meta, "", "  ")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTimestampsCSV(t *testing.T) {
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// a gap in the recording restarts the timing of the second segment
+	segments := []TimeSegment{
+		{SampleStart: 0, Samples: 2500, Time: start},
+		{SampleStart: 2500, Samples: 1000, Time: start.Add(10 * time.Second)},
+	}
+
+	got := string(formatTimestampsCSV(segments, 1000, time.Second))
+	want := "sample,unix_ns,time\n" +
+		"0,1664625600000000000,2022-10-01T12:00:00Z\n" +
+		"1000,1664625601000000000,2022-10-01T12:00:01Z\n" +
+		"2000,1664625602000000000,2022-10-01T12:00:02Z\n" +
+		"2500,1664625610000000000,2022-10-01T12:00:10Z\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// samples between rows are interpolated
+	if at := sampleTime(segments, 1000, 1250); !at.Equal(start.Add(1250 * time.Millisecond)) {
+		t.Errorf("sample 1250 at %s", at)
+	}
+	if at := sampleTime(segments, 1000, 3000); !at.Equal(start.Add(10500 * time.Millisecond)) {
+		t.Errorf("sample 3000 at %s", at)
+	}
+}
+
+func TestFormatTimestampsSigMF(t *testing.T) {
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	segments := []TimeSegment{
+		{SampleStart: 0, Samples: 100, Time: start},
+		{SampleStart: 100, Samples: 100, Time: start.Add(time.Minute)},
+	}
+	h := Header{SampleRate: 48000, CenterFreq: 7000000}
+	plan := outputPlan{SampleRate: 24000, BitsPerSample: 8, Decimation: 2}
+
+	body, err := formatTimestampsSigMF(segments, h, plan, "/tmp/out/raw-iq.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var meta struct {
+		Global   map[string]interface{}   `json:"global"`
+		Captures []map[string]interface{} `json:"captures"`
+	}
+	err = json.Unmarshal(body, &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Global["core:datatype"] != "cu8" || meta.Global["core:sample_rate"] != 24000.0 || meta.Global["core:dataset"] != "raw-iq.wav" {
+		t.Errorf("global: %v", meta.Global)
+	}
+	if len(meta.Captures) != 2 {
+		t.Fatalf("got %d captures", len(meta.Captures))
+	}
+	if meta.Captures[0]["core:header_bytes"] != 44.0 || meta.Captures[1]["core:header_bytes"] != nil {
+		t.Error("only the first capture skips the WAV header")
+	}
+	if meta.Captures[1]["core:sample_start"] != 100.0 || !strings.HasPrefix(meta.Captures[1]["core:datetime"].(string), "2022-10-01T12:01:00") {
+		t.Errorf("second capture: %v", meta.Captures[1])
+	}
+}
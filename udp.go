@@ -0,0 +1,78 @@
+//go:build !minimal
+
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"net"
+	"time"
+)
+
+// payload bytes per datagram, a whole number of 8-bit or 16-bit I/Q pairs
+const udpDatagramSize = 1024
+
+type udpStream struct {
+	conn     net.Conn
+	buf      []byte
+	byteRate float64
+	pace     bool
+	start    time.Time
+	sent     int64
+	failed   bool
+}
+
+/**
+ * Opens a UDP stream of raw sample bytes. When paced, datagrams leave at byteRate bytes per second
+ * so live consumers see the recording in real time.
+ */
+func newUDPStream(address string, byteRate uint32, pace bool) (*udpStream, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &udpStream{conn: conn, byteRate: float64(byteRate), pace: pace}, nil
+}
+
+/**
+ * Buffers p and sends every complete datagram. Network errors are logged once and the data is dropped,
+ * so a monitoring hiccup never interrupts the file being archived.
+ */
+func (s *udpStream) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for len(s.buf) >= udpDatagramSize {
+		s.send(s.buf[:udpDatagramSize])
+		s.buf = s.buf[udpDatagramSize:]
+	}
+
+	return len(p), nil
+}
+
+/**
+ * Flushes the remaining bytes and closes the connection
+ */
+func (s *udpStream) Close() error {
+	if len(s.buf) > 0 {
+		s.send(s.buf)
+		s.buf = nil
+	}
+
+	return s.conn.Close()
+}
+
+func (s *udpStream) send(datagram []byte) {
+	if s.pace {
+		if s.start.IsZero() {
+			s.start = time.Now()
+		}
+		due := s.start.Add(time.Duration(float64(s.sent) / s.byteRate * float64(time.Second)))
+		time.Sleep(time.Until(due))
+	}
+
+	_, err := s.conn.Write(datagram)
+	if err != nil && !s.failed {
+		logrus.WithError(err).Warn("UDP stream error, dropping samples")
+		s.failed = true
+	}
+	s.sent += int64(len(datagram))
+}
@@ -0,0 +1,63 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPStream(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback UDP:", err)
+	}
+	defer listener.Close()
+
+	// paced at 20 KiB/s the third datagram leaves 100 ms after the first
+	stream, err := newUDPStream(listener.LocalAddr().String(), 20480, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := make([]byte, 2500)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+
+	started := time.Now()
+	for _, chunk := range [][]byte{payload[:100], payload[100:1500], payload[1500:]} {
+		n, err := stream.Write(chunk)
+		if n != len(chunk) || err != nil {
+			t.Fatalf("write: %d, %v", n, err)
+		}
+	}
+	err = stream.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Errorf("stream not paced, took %s", elapsed)
+	}
+
+	// whole datagrams, then the tail flushed on close
+	var received []byte
+	buf := make([]byte, 2*udpDatagramSize)
+	for _, size := range []int{udpDatagramSize, udpDatagramSize, 2500 - 2*udpDatagramSize} {
+		listener.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != size {
+			t.Errorf("got a %d byte datagram, want %d", n, size)
+		}
+		received = append(received, buf[:n]...)
+	}
+
+	if !bytes.Equal(received, payload) {
+		t.Error("received bytes differ from the written ones")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "errors"
+
+/**
+ * The umask is not available on this platform
+ */
+func setUmask(mask int) error {
+	return errors.New("umask not supported on this platform")
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import "syscall"
+
+/**
+ * Sets the process umask for every file created afterwards
+ */
+func setUmask(mask int) error {
+	syscall.Umask(mask)
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// samples converted per write when streaming
+const convertChunk = 16384
+
+/**
+ * Builds a 44-byte PCM wave header, the RIFF and data sizes are left at zero
+ */
+func wavHeader(sampleRate uint32, channels int, bitsPerSample int) []byte {
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:], sampleRate)
+	binary.LittleEndian.PutUint32(header[28:], sampleRate*uint32(channels*bitsPerSample/8))
+	binary.LittleEndian.PutUint16(header[32:], uint16(channels*bitsPerSample/8))
+	binary.LittleEndian.PutUint16(header[34:], uint16(bitsPerSample))
+	copy(header[36:], "data")
+
+	return header
+}
+
+/**
+ * Writes the RIFF and data sizes into a wave file whose header was written by wavHeader
+ */
+func finalizeWav(f *os.File, dataSize int64) error {
+	sizes := make([]byte, 4)
+
+	binary.LittleEndian.PutUint32(sizes, uint32(dataSize+36))
+	if _, err := f.WriteAt(sizes, 4); err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(sizes, uint32(dataSize))
+	_, err := f.WriteAt(sizes, 40)
+
+	return err
+}
+
+/**
+ * Converts the payload into 16-bit I/Q pairs chunk by chunk, returns the number of bytes written.
+ * 24-bit recordings hold each sample in a 32-bit word and keep its upper 16 bits, 16-bit recordings are copied as is.
+ */
+func convertTo16BitStream(w io.Writer, payload []byte, sampleSize uint32) (int64, error) {
+	if sampleSize == 16 {
+		n, err := w.Write(payload[:len(payload)-len(payload)%4])
+		return int64(n), err
+	}
+
+	var written int64
+	buf := make([]byte, convertChunk*2)
+
+	for start := 0; start+4 <= len(payload); start += convertChunk * 4 {
+		n := 0
+		for i := start; i+4 <= len(payload) && n < len(buf); i += 4 {
+			sample := int32(binary.LittleEndian.Uint32(payload[i:]))
+			sample = sample << 8
+			sample = sample >> 16
+
+			binary.LittleEndian.PutUint16(buf[n:], uint16(sample))
+			n += 2
+		}
+
+		m, err := w.Write(buf[:n])
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+/**
+ * Decodes a 2-channel PCM WAV into normalized complex samples, channel 0 is I and channel 1 is Q
+ */
+func decodeWav(content []byte) ([]complex64, uint32, error) {
+	if len(content) < 12 || string(content[0:4]) != "RIFF" || string(content[8:12]) != "WAVE" {
+		return nil, 0, errors.New("not a WAV file")
+	}
+
+	var sampleRate uint32
+	var bitsPerSample int
+	for offset := 12; offset+8 <= len(content); {
+		id := string(content[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(content[offset+4:]))
+		chunk := content[offset+8:]
+		if size < len(chunk) {
+			chunk = chunk[:size]
+		}
+
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, 0, errors.New("truncated fmt chunk")
+			}
+			if format := binary.LittleEndian.Uint16(chunk[0:2]); format != 1 {
+				return nil, 0, fmt.Errorf("unsupported WAV format %d, only PCM is supported", format)
+			}
+			if channels := binary.LittleEndian.Uint16(chunk[2:4]); channels != 2 {
+				return nil, 0, fmt.Errorf("expected 2 channels, got %d", channels)
+			}
+			sampleRate = binary.LittleEndian.Uint32(chunk[4:8])
+			bitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:16]))
+		case "data":
+			if bitsPerSample == 0 {
+				return nil, 0, errors.New("data chunk before fmt chunk")
+			}
+			samples, err := decodePCM(chunk, bitsPerSample)
+			return samples, sampleRate, err
+		}
+
+		// chunks are padded to an even size
+		offset += 8 + size + size%2
+	}
+
+	return nil, 0, errors.New("no data chunk")
+}
+
+/**
+ * Decodes interleaved I/Q PCM samples of the given bit depth
+ */
+func decodePCM(data []byte, bitsPerSample int) ([]complex64, error) {
+	width := bitsPerSample / 8
+	if width < 1 || width > 4 || bitsPerSample%8 != 0 {
+		return nil, fmt.Errorf("unsupported bit depth %d", bitsPerSample)
+	}
+
+	value := func(b []byte) float32 {
+		switch width {
+		case 1:
+			// 8-bit WAV is unsigned
+			return float32(int(b[0])-128) / (1 << 7)
+		case 2:
+			return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		case 3:
+			return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+		return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+
+	samples := make([]complex64, len(data)/(2*width))
+	for i := range samples {
+		samples[i] = complex(value(data[i*2*width:]), value(data[i*2*width+width:]))
+	}
+
+	return samples, nil
+}
+
+/**
+ * Quantizes normalized samples to 8-bit (unsigned) or 16-bit PCM chunk by chunk, returns the number of bytes written
+ */
+func writeSamples(w io.Writer, samples []complex64, bitsPerSample int) (int64, error) {
+	width := bitsPerSample / 8
+	scale := math.Exp2(float64(bitsPerSample - 1))
+	quantize := func(v float32) int {
+		q := int(math.Floor(float64(v) * scale))
+		if q < -int(scale) {
+			return -int(scale)
+		}
+		if q > int(scale)-1 {
+			return int(scale) - 1
+		}
+		return q
+	}
+
+	var written int64
+	buf := make([]byte, convertChunk*2*width)
+	for start := 0; start < len(samples); start += convertChunk {
+		n := 0
+		for i := start; i < len(samples) && i < start+convertChunk; i++ {
+			for _, v := range []float32{real(samples[i]), imag(samples[i])} {
+				if width == 1 {
+					// 8-bit WAV is unsigned
+					buf[n] = byte(quantize(v) + 128)
+				} else {
+					binary.LittleEndian.PutUint16(buf[n:], uint16(int16(quantize(v))))
+				}
+				n += width
+			}
+		}
+
+		m, err := w.Write(buf[:n])
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
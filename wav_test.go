@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestConvertTo16BitStream(t *testing.T) {
+	// 24-bit samples in 32-bit words keep their upper 16 bits
+	words := []int32{0x123456, -0x123456, 0x7FFFFF, -0x800000}
+	payload := make([]byte, 4*len(words))
+	for i, v := range words {
+		binary.LittleEndian.PutUint32(payload[i*4:], uint32(v))
+	}
+
+	var out bytes.Buffer
+	n, err := convertTo16BitStream(&out, payload, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 || out.Len() != 8 {
+		t.Fatalf("wrote %d bytes, want 8", n)
+	}
+	for i, want := range []int16{0x1234, -0x1235, 0x7FFF, -0x8000} {
+		if got := int16(binary.LittleEndian.Uint16(out.Bytes()[i*2:])); got != want {
+			t.Errorf("sample %d: got %#x, want %#x", i, got, want)
+		}
+	}
+
+	// 16-bit I/Q pairs are copied, a trailing partial pair is dropped
+	payload = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	out.Reset()
+	n, err = convertTo16BitStream(&out, payload, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 || !bytes.Equal(out.Bytes(), payload[:8]) {
+		t.Errorf("got %v, want %v", out.Bytes(), payload[:8])
+	}
+}
+
+func TestOutputPlanSize(t *testing.T) {
+	// the planned size is what the default conversion writes, for both sample sizes
+	for _, sampleSize := range []uint32{16, 24} {
+		h := Header{SampleRate: 48000, SampleSize: sampleSize}
+		payload := make([]byte, 1000*frameSize(sampleSize))
+
+		var out bytes.Buffer
+		n, err := convertTo16BitStream(&out, payload, sampleSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames := int64(len(payload) / frameSize(sampleSize))
+		if size := defaultPlan(h).size(frames); size != 44+n {
+			t.Errorf("%d-bit: planned %d bytes, wrote %d", sampleSize, size, 44+n)
+		}
+	}
+}
+
+func TestWriteSamplesRoundTrip(t *testing.T) {
+	samples := []complex64{complex(0.5, -0.25), complex(-1, 0.999)}
+
+	var out bytes.Buffer
+	_, err := writeSamples(&out, samples, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wav := append(wavHeader(48000, 2, 16), out.Bytes()...)
+	binary.LittleEndian.PutUint32(wav[40:], uint32(out.Len()))
+	decoded, rate, err := decodeWav(wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 48000 || len(decoded) != len(samples) {
+		t.Fatalf("got %d samples at %d Hz", len(decoded), rate)
+	}
+	for i := range samples {
+		if d := decoded[i] - samples[i]; real(d) > 1e-3 || real(d) < -1e-3 || imag(d) > 1e-3 || imag(d) < -1e-3 {
+			t.Errorf("sample %d: got %v, want %v", i, decoded[i], samples[i])
+		}
+	}
+}